@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"tribler-arr-shim/pkg/logbuffer"
 	"tribler-arr-shim/pkg/storage"
 
 	torrent "tribler-arr-shim/pkg/torrent"
@@ -40,7 +42,9 @@ func StartServer() {
 	db, err := storage.New(db_path, initsql_path)
 	defer db.Close()
 
-	r := apiv2Routes(db)
+	handler := torrent.NewHandler(db)
+	go handler.RunMonitor(torrent.MonitorInterval)
+	r := apiv2Routes(handler)
 
 	// scheme := os.Getenv("TRIBLER_ARR_SHIM_SCHEME")
 	addr := os.Getenv("TRIBLER_ARR_SHIM_ADDR")
@@ -64,7 +68,7 @@ func StartServer() {
 		_ = torrent.ImportNonCategorisedTorrents(db, default_category)
 	}
 
-	log.Printf("Starting server on %s", serverAddr)
+	logbuffer.Info("Starting server on %s", serverAddr)
 	err = http.ListenAndServe(serverAddr, r)
 }
 
@@ -73,9 +77,26 @@ func loggingMiddleware(c *gin.Context) {
 	c.Next()
 }
 
-func apiv2Routes(db storage.Database) *gin.Engine {
-	handler := torrent.NewHandler(db)
+// trustedProxies returns the comma separated proxies of TRIBLER_ARR_SHIM_TRUSTED_PROXIES, none by default
+func trustedProxies() []string {
+	proxies := []string{}
+	for _, proxy := range strings.Split(os.Getenv("TRIBLER_ARR_SHIM_TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	if len(proxies) == 0 {
+		return nil
+	}
+	return proxies
+}
+
+func apiv2Routes(handler *torrent.Handler) *gin.Engine {
 	r := gin.Default()
+	// ClientIP decides login bans, so X-Forwarded-For is only believed when it comes from a configured proxy
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal("Error setting trusted proxies: ", err)
+	}
 	gob.Register(map[string]interface{}{})
 	r.Use(sessions.Sessions("tribler-arr-shim", cookiestore.NewStore([]byte(os.Getenv("SESSION_SECRET")))))
 	r.Use(loggingMiddleware)
@@ -83,23 +104,65 @@ func apiv2Routes(db storage.Database) *gin.Engine {
 	// r.GET("/api/auth/callback", authentication.CallbackHandler(authenticator, ()))
 	// r.GET("/api/user", isAuthenticatedFn(), user.GetUserInfoHandler())
 	// user subscription
-	r.GET("/api/v2/app/webapiVersion", handler.GetWebApiVersion())
-	r.GET("/api/v2/app/version", handler.GetVersion())
-	r.GET("/api/v2/app/preferences", handler.GetAppPreferences())
-	r.GET("/api/v2/torrents/info", handler.GetInfo())
-	r.GET("/api/v2/torrents/properties", handler.GetProperties())
-	r.GET("/api/v2/torrents/files", handler.GetTorrentsContents())
-
-	r.POST("/api/v2/torrents/add", handler.Add())
-	r.POST("/api/v2/torrents/delete", handler.Delete())
-	r.POST("/api/v2/torrents/setCategory", handler.SetCategory())
-	r.GET("/api/v2/torrents/categories", handler.GetCategories())
-	r.POST("/api/v2/torrents/setShareLimits", handler.SetShareLimits())
-	r.POST("/api/v2/torrents/topPrio", handler.SetTopPriority())
-	r.POST("/api/v2/torrents/pause", handler.PauseTorrent())
-	r.POST("/api/v2/torrents/resume", handler.ResumeTorrent())
-	r.POST("/api/v2/torrents/setForceStart", handler.SetForceStartTorrent())
-	r.POST("/api/v2/torrents/createCategory", handler.CreateCategory())
+
+	// everything but login requires a valid SID, like qBittorrent
+	authorized := r.Group("/")
+	authorized.Use(handler.AuthMiddleware())
+	authorized.POST("/api/v2/auth/logout", handler.LogoutHandler())
+	authorized.GET("/api/v2/app/webapiVersion", handler.GetWebApiVersion())
+	authorized.GET("/api/v2/app/version", handler.GetVersion())
+	authorized.GET("/api/v2/app/preferences", handler.GetAppPreferences())
+	authorized.POST("/api/v2/app/setPreferences", handler.SetAppPreferences())
+	authorized.GET("/api/v2/sync/maindata", handler.GetMainData())
+	authorized.GET("/api/v2/log/main", handler.GetMainLog())
+	authorized.GET("/api/v2/log/peers", handler.GetPeerLog())
+	authorized.GET("/api/v2/transfer/info", handler.GetTransferInfo())
+	authorized.GET("/api/v2/transfer/downloadLimit", handler.GetDownloadLimit())
+	authorized.POST("/api/v2/transfer/downloadLimit", handler.GetDownloadLimit())
+	authorized.GET("/api/v2/transfer/uploadLimit", handler.GetUploadLimit())
+	authorized.POST("/api/v2/transfer/uploadLimit", handler.GetUploadLimit())
+	authorized.POST("/api/v2/transfer/setDownloadLimit", handler.SetDownloadLimit())
+	authorized.POST("/api/v2/transfer/setUploadLimit", handler.SetUploadLimit())
+	authorized.GET("/api/v2/sync/torrentPeers", handler.GetTorrentPeers())
+	authorized.GET("/api/v2/torrents/info", handler.GetInfo())
+	authorized.GET("/api/v2/torrents/properties", handler.GetProperties())
+	authorized.GET("/api/v2/torrents/files", handler.GetTorrentsContents())
+
+	authorized.POST("/api/v2/torrents/add", handler.Add())
+	authorized.POST("/api/v2/torrents/delete", handler.Delete())
+	authorized.POST("/api/v2/torrents/setCategory", handler.SetCategory())
+	authorized.GET("/api/v2/torrents/categories", handler.GetCategories())
+	authorized.POST("/api/v2/torrents/setShareLimits", handler.SetShareLimits())
+	authorized.POST("/api/v2/torrents/downloadLimit", handler.GetTorrentDownloadLimit())
+	authorized.POST("/api/v2/torrents/setDownloadLimit", handler.SetTorrentDownloadLimit())
+	authorized.POST("/api/v2/torrents/uploadLimit", handler.GetTorrentUploadLimit())
+	authorized.POST("/api/v2/torrents/setUploadLimit", handler.SetTorrentUploadLimit())
+	authorized.POST("/api/v2/torrents/filePrio", handler.SetFilePriority())
+	authorized.GET("/api/v2/torrents/trackers", handler.GetTrackers())
+	authorized.POST("/api/v2/torrents/addTrackers", handler.AddTrackers())
+	authorized.POST("/api/v2/torrents/editTracker", handler.EditTracker())
+	authorized.POST("/api/v2/torrents/removeTrackers", handler.RemoveTrackers())
+	authorized.POST("/api/v2/torrents/recheck", handler.RecheckTorrent())
+	authorized.POST("/api/v2/torrents/reannounce", handler.Reannounce())
+	authorized.POST("/api/v2/torrents/rename", handler.RenameTorrent())
+	authorized.POST("/api/v2/torrents/renameFile", handler.RenameFile())
+	authorized.POST("/api/v2/torrents/renameFolder", handler.RenameFolder())
+	authorized.POST("/api/v2/torrents/setLocation", handler.SetLocation())
+	authorized.POST("/api/v2/torrents/topPrio", handler.SetTopPriority())
+	authorized.POST("/api/v2/torrents/bottomPrio", handler.SetBottomPriority())
+	authorized.POST("/api/v2/torrents/increasePrio", handler.IncreasePriority())
+	authorized.POST("/api/v2/torrents/decreasePrio", handler.DecreasePriority())
+	authorized.POST("/api/v2/torrents/pause", handler.PauseTorrent())
+	authorized.POST("/api/v2/torrents/resume", handler.ResumeTorrent())
+	authorized.POST("/api/v2/torrents/setForceStart", handler.SetForceStartTorrent())
+	authorized.POST("/api/v2/torrents/createCategory", handler.CreateCategory())
+	authorized.POST("/api/v2/torrents/editCategory", handler.EditCategory())
+	authorized.POST("/api/v2/torrents/removeCategories", handler.RemoveCategories())
+	authorized.GET("/api/v2/torrents/tags", handler.GetTags())
+	authorized.POST("/api/v2/torrents/createTags", handler.CreateTags())
+	authorized.POST("/api/v2/torrents/deleteTags", handler.DeleteTags())
+	authorized.POST("/api/v2/torrents/addTags", handler.AddTags())
+	authorized.POST("/api/v2/torrents/removeTags", handler.RemoveTags())
 
 	return r
 }
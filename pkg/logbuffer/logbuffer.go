@@ -0,0 +1,154 @@
+package logbuffer
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Message types as used by qBittorrent's /log/main
+const (
+	TypeNormal   = 1
+	TypeInfo     = 2
+	TypeWarning  = 4
+	TypeCritical = 8
+)
+
+// DefaultSize is how many entries the shared buffers keep
+const DefaultSize = 2000
+
+// Entry is a log message
+type Entry struct {
+	ID        int    `json:"id"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+	Type      int    `json:"type"`
+}
+
+// PeerEntry is a peer that was blocked or let through
+type PeerEntry struct {
+	ID        int    `json:"id"`
+	IP        string `json:"ip"`
+	Timestamp int64  `json:"timestamp"`
+	Blocked   bool   `json:"blocked"`
+	Reason    string `json:"reason"`
+}
+
+// Main receives the messages logged with Normal, Info, Warning and Critical
+var Main = New(DefaultSize)
+
+// Peers records the clients the shim bans
+var Peers = NewPeers(DefaultSize)
+
+// Buffer keeps the last size log messages
+type Buffer struct {
+	mu      sync.Mutex
+	size    int
+	entries []Entry
+	nextID  int
+}
+
+// New returns a buffer that keeps the last size messages
+func New(size int) *Buffer {
+	return &Buffer{size: size}
+}
+
+// Add stores a message of the given type
+func (b *Buffer) Add(message string, messageType int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, Entry{
+		ID:        b.nextID,
+		Message:   message,
+		Timestamp: time.Now().UnixMilli(),
+		Type:      messageType,
+	})
+	b.nextID++
+	if len(b.entries) > b.size {
+		b.entries = b.entries[len(b.entries)-b.size:]
+	}
+}
+
+// Entries returns the messages after lastKnownID whose type is in types, a mask of the Type constants
+func (b *Buffer) Entries(lastKnownID int, types int) []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entries := []Entry{}
+	for _, entry := range b.entries {
+		if entry.ID > lastKnownID && entry.Type&types != 0 {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// logf logs a message with the log package and stores it in Main with the given type
+func logf(messageType int, format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	log.Print(message)
+	Main.Add(message, messageType)
+}
+
+// Normal logs a message of the normal type
+func Normal(format string, v ...interface{}) {
+	logf(TypeNormal, format, v...)
+}
+
+// Info logs an event worth showing in the log of the web UI
+func Info(format string, v ...interface{}) {
+	logf(TypeInfo, format, v...)
+}
+
+// Warning logs something that went wrong without stopping the shim from doing its job
+func Warning(format string, v ...interface{}) {
+	logf(TypeWarning, format, v...)
+}
+
+// Critical logs a failure that made a request or task fail
+func Critical(format string, v ...interface{}) {
+	logf(TypeCritical, format, v...)
+}
+
+// PeerBuffer keeps the last size peer entries
+type PeerBuffer struct {
+	mu      sync.Mutex
+	size    int
+	entries []PeerEntry
+	nextID  int
+}
+
+// NewPeers returns a buffer that keeps the last size peer entries
+func NewPeers(size int) *PeerBuffer {
+	return &PeerBuffer{size: size}
+}
+
+// Add records a peer
+func (b *PeerBuffer) Add(ip string, blocked bool, reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = append(b.entries, PeerEntry{
+		ID:        b.nextID,
+		IP:        ip,
+		Timestamp: time.Now().UnixMilli(),
+		Blocked:   blocked,
+		Reason:    reason,
+	})
+	b.nextID++
+	if len(b.entries) > b.size {
+		b.entries = b.entries[len(b.entries)-b.size:]
+	}
+}
+
+// Entries returns the peer entries after lastKnownID
+func (b *PeerBuffer) Entries(lastKnownID int) []PeerEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entries := []PeerEntry{}
+	for _, entry := range b.entries {
+		if entry.ID > lastKnownID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
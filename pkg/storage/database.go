@@ -5,6 +5,7 @@ import (
 	"errors"
 	"log"
 	"os"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -16,9 +17,46 @@ type Database interface {
 	AddTorrent(torrent Torrent) error
 	DeleteTorrent(hash string) error
 	AddCategory(category, savePath string) error
+	GetCategory(category string) (Category, error)
+	EditCategory(category, savePath string) error
+	RemoveCategories(categories []string) error
+	SetTorrentCategory(hash, category string) error
+	GetTags() ([]string, error)
+	CreateTags(tags []string) error
+	DeleteTags(tags []string) error
+	AddTorrentTags(hashes, tags []string) error
+	RemoveTorrentTags(hashes, tags []string) error
+	GetTorrentTags() (map[string][]string, error)
+	GetPreferences() (map[string]string, error)
+	SetPreferences(preferences map[string]string) error
+	SetShareLimits(hashes []string, limits ShareLimits) error
+	GetShareLimits() (map[string]ShareLimits, error)
+	GetTorrentStats() (map[string]TorrentStats, error)
+	SaveTorrentStats(stats []TorrentStats) error
+	GetQueue() ([]QueueEntry, error)
+	SaveQueueOrder(hashes []string) error
+	SetQueued(hashes []string, queued bool) error
+	SetForceStart(hashes []string, forceStart bool) error
+	GetForceStarted() (map[string]bool, error)
+	SetTorrentName(hash, name string) error
+	GetTorrentNames() (map[string]string, error)
+	SetTorrentFileNames(hash string, names map[int]string) error
+	GetTorrentFileNames(hash string) (map[int]string, error)
+	GetRenamedTorrents() (map[string]bool, error)
+	SetTorrentSavePath(hash, savePath string) error
+	GetTorrentSavePaths() (map[string]string, error)
+	ForgetTorrent(hash string) error
+	AddSession(sid string, expiresAt time.Time) error
+	SessionValid(sid string) (bool, error)
+	RefreshSession(sid string, expiresAt time.Time) error
+	DeleteSession(sid string) error
+	DeleteExpiredSessions() error
 	Close() error
 }
 
+// ErrCategoryNotFound is returned when a category name does not exist in the database
+var ErrCategoryNotFound = errors.New("category does not exist")
+
 type SQLite struct {
 	*sql.DB
 }
@@ -48,6 +86,9 @@ func New(db_location, init_sql string) (Database, error) {
 	if err != nil {
 		log.Fatal("Error executing "+db_location+": ", err)
 	}
+	if err := migrate(db); err != nil {
+		log.Fatal("Error migrating "+db_location+": ", err)
+	}
 	return &SQLite{db}, nil
 }
 
@@ -125,9 +166,12 @@ func (db *SQLite) AddTorrent(torrent Torrent) error {
 	return tx.Commit()
 }
 
-// GetAllTorrents returns all torrents in the database
+// GetAllTorrents returns all torrents in the database. Torrents without a category have an empty category.
 func (db *SQLite) GetAllTorrents() ([]Torrent, error) {
-	rows, err := db.Query("SELECT hash, category_id FROM torrent")
+	rows, err := db.Query(
+		`SELECT t.hash, COALESCE(c.name, '') as category
+    FROM torrent as t
+    LEFT JOIN category as c ON t.category_id = c.id`)
 	if err != nil {
 		return nil, err
 	}
@@ -162,11 +206,108 @@ func (db *SQLite) AddCategory(category, savePath string) error {
 	return err
 }
 
+// GetCategory returns a single category by name, or ErrCategoryNotFound
+func (db *SQLite) GetCategory(category string) (Category, error) {
+	var c Category
+	err := db.QueryRow("SELECT id, name, savePath FROM category WHERE name = ?", category).Scan(&c.ID, &c.Name, &c.SavePath)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Category{}, ErrCategoryNotFound
+	}
+	return c, err
+}
+
+// EditCategory changes the save path of an existing category, or returns ErrCategoryNotFound
+func (db *SQLite) EditCategory(category, savePath string) error {
+	result, err := db.Exec("UPDATE category SET savePath = ? WHERE name = ?", savePath, category)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// RemoveCategories deletes categories. Torrents in a removed category are kept without a category.
+func (db *SQLite) RemoveCategories(categories []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, category := range categories {
+		log.Println("Removing category:", category)
+		_, err = tx.Exec("UPDATE torrent SET category_id = NULL WHERE category_id IN (SELECT id FROM category WHERE name = ?)", category)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM category WHERE name = ?", category)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SetTorrentCategory moves a torrent to an existing category. An empty category keeps the torrent with a
+// NULL category_id, so that it is not imported into the default category again.
+func (db *SQLite) SetTorrentCategory(hash, category string) error {
+	var categoryID sql.NullInt64
+	if category != "" {
+		c, err := db.GetCategory(category)
+		if err != nil {
+			return err
+		}
+		categoryID = sql.NullInt64{Int64: c.ID, Valid: true}
+	}
+
+	_, err := db.Exec(
+		`INSERT INTO torrent (hash, category_id) VALUES (?, ?)
+    ON CONFLICT(hash) DO UPDATE SET category_id = excluded.category_id`, hash, categoryID)
+	return err
+}
+
 func (db *SQLite) DeleteTorrent(hash string) error {
 	_, err := db.Exec("DELETE FROM torrent WHERE hash = ?", hash)
 	return err
 }
 
+// torrentTables are the tables that store data of a single torrent keyed by its hash
+var torrentTables = []string{
+	"torrent",
+	"torrent_tag",
+	"torrent_share_limit",
+	"torrent_stats",
+	"torrent_queue",
+	"torrent_force_start",
+	"torrent_name",
+	"torrent_file_name",
+	"torrent_save_path",
+}
+
+// ForgetTorrent removes everything stored for a torrent that has been deleted from Tribler
+func (db *SQLite) ForgetTorrent(hash string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, table := range torrentTables {
+		_, err = tx.Exec("DELETE FROM "+table+" WHERE hash = ?", hash)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // Close closes the database connection
 func (db *SQLite) Close() error {
 	return db.DB.Close()
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// baselineSchema is the torrent and category schema databases were created with before category_id became nullable
+const baselineSchema = `
+CREATE TABLE torrent (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    hash TEXT NOT NULL UNIQUE,
+    category_id INTEGER NOT NULL,
+    FOREIGN KEY (category_id) REFERENCES category(id)
+);
+
+CREATE TABLE category (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    savePath TEXT NOT NULL
+);
+
+INSERT INTO category (name, savePath) VALUES ('tv', ''), ('movies', '');
+INSERT INTO torrent (hash, category_id) VALUES ('aaaa', 1), ('bbbb', 2);
+`
+
+// newBaselineDatabase opens a database that starts out with the baseline schema
+func newBaselineDatabase(t *testing.T) Database {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "database.db")
+
+	baseline, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := baseline.Exec(baselineSchema); err != nil {
+		t.Fatal(err)
+	}
+	baseline.Close()
+
+	db, err := New(path, filepath.Join("..", "..", "scripts", "init_db.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func torrentCategories(t *testing.T, db Database) map[string]string {
+	t.Helper()
+	torrents, err := db.GetAllTorrents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	categories := map[string]string{}
+	for _, torrent := range torrents {
+		categories[torrent.Hash] = torrent.Category
+	}
+	return categories
+}
+
+func TestRemoveCategoriesKeepsTorrents(t *testing.T) {
+	db := newBaselineDatabase(t)
+
+	if err := db.RemoveCategories([]string{"tv"}); err != nil {
+		t.Fatalf("RemoveCategories() = %v", err)
+	}
+
+	want := map[string]string{"aaaa": "", "bbbb": "movies"}
+	got := torrentCategories(t, db)
+	if len(got) != len(want) {
+		t.Fatalf("torrents = %v, want %v", got, want)
+	}
+	for hash, category := range want {
+		if got[hash] != category {
+			t.Errorf("category of %s = %q, want %q", hash, got[hash], category)
+		}
+	}
+}
+
+func TestSetTorrentCategoryEmpty(t *testing.T) {
+	db := newBaselineDatabase(t)
+
+	if err := db.SetTorrentCategory("bbbb", ""); err != nil {
+		t.Fatalf("SetTorrentCategory() = %v", err)
+	}
+	if err := db.SetTorrentCategory("cccc", ""); err != nil {
+		t.Fatalf("SetTorrentCategory() = %v", err)
+	}
+
+	got := torrentCategories(t, db)
+	for _, hash := range []string{"bbbb", "cccc"} {
+		category, ok := got[hash]
+		if !ok || category != "" {
+			t.Errorf("category of %s = %q (stored %v), want it stored without a category", hash, category, ok)
+		}
+	}
+	if got["aaaa"] != "tv" {
+		t.Errorf("category of aaaa = %q, want %q", got["aaaa"], "tv")
+	}
+}
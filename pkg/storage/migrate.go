@@ -0,0 +1,113 @@
+package storage
+
+import "database/sql"
+
+// column is a column added to a table after the table was first created
+type column struct {
+	table      string
+	name       string
+	definition string
+}
+
+// addedColumns are added to databases created before they were part of init_db.sql,
+// as CREATE TABLE IF NOT EXISTS leaves existing tables alone
+var addedColumns = []column{
+	{"torrent_stats", "active_time", "INTEGER NOT NULL DEFAULT 0"},
+	{"torrent_stats", "last_seen_complete", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// relaxedColumn is a column that was NOT NULL when its table was first created. SQLite cannot drop the
+// constraint, so the table is rebuilt from create and its rows copied over.
+type relaxedColumn struct {
+	table   string
+	name    string
+	create  string
+	columns string
+}
+
+// relaxedColumns are rebuilt in databases created before the columns became nullable
+var relaxedColumns = []relaxedColumn{
+	{
+		table: "torrent",
+		name:  "category_id",
+		create: `CREATE TABLE torrent_migrated (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    hash TEXT NOT NULL UNIQUE,
+    category_id INTEGER,
+    FOREIGN KEY (category_id) REFERENCES category(id)
+)`,
+		columns: "id, hash, category_id",
+	},
+}
+
+// migrate adds the columns existing tables are missing and rebuilds the tables whose columns became nullable
+func migrate(db *sql.DB) error {
+	for _, c := range relaxedColumns {
+		if err := relax(db, c); err != nil {
+			return err
+		}
+	}
+	for _, c := range addedColumns {
+		exists, err := hasColumn(db, c.table, c.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		_, err = db.Exec("ALTER TABLE " + c.table + " ADD COLUMN " + c.name + " " + c.definition)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relax rebuilds the table of c if its column is still NOT NULL
+func relax(db *sql.DB, c relaxedColumn) error {
+	var notNull bool
+	err := db.QueryRow("SELECT \"notnull\" FROM pragma_table_info(?) WHERE name = ?", c.table, c.name).Scan(&notNull)
+	if err != nil {
+		return err
+	}
+	if !notNull {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	statements := []string{
+		c.create,
+		"INSERT INTO " + c.table + "_migrated (" + c.columns + ") SELECT " + c.columns + " FROM " + c.table,
+		"DROP TABLE " + c.table,
+		"ALTER TABLE " + c.table + "_migrated RENAME TO " + c.table,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func hasColumn(db *sql.DB, table, name string) (bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return false, err
+		}
+		if column == name {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
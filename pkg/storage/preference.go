@@ -0,0 +1,36 @@
+package storage
+
+// GetPreferences returns all stored preferences keyed by name. Values are JSON encoded.
+func (db *SQLite) GetPreferences() (map[string]string, error) {
+	rows, err := db.Query("SELECT key, value FROM preference")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preferences := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		preferences[key] = value
+	}
+	return preferences, rows.Err()
+}
+
+// SetPreferences inserts or replaces the given preferences
+func (db *SQLite) SetPreferences(preferences map[string]string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for key, value := range preferences {
+		_, err = tx.Exec("INSERT OR REPLACE INTO preference (key, value) VALUES (?, ?)", key, value)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
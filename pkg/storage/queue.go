@@ -0,0 +1,103 @@
+package storage
+
+// QueueEntry is the position of a torrent in the shim's download queue
+type QueueEntry struct {
+	Hash     string
+	Position int
+	Queued   bool
+}
+
+// GetQueue returns all queue entries ordered by position
+func (db *SQLite) GetQueue() ([]QueueEntry, error) {
+	rows, err := db.Query("SELECT hash, position, queued FROM torrent_queue ORDER BY position ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []QueueEntry{}
+	for rows.Next() {
+		var entry QueueEntry
+		if err := rows.Scan(&entry.Hash, &entry.Position, &entry.Queued); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// SaveQueueOrder gives the hashes the positions 1..n in the order they are passed, keeping their queued flag
+func (db *SQLite) SaveQueueOrder(hashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for i, hash := range hashes {
+		_, err = tx.Exec(
+			`INSERT INTO torrent_queue (hash, position) VALUES (?, ?)
+    ON CONFLICT(hash) DO UPDATE SET position = excluded.position`, hash, i+1)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetQueued marks torrents as stopped by the queue or not. Torrents without a queue entry are added at the end.
+func (db *SQLite) SetQueued(hashes []string, queued bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		_, err = tx.Exec(
+			`INSERT INTO torrent_queue (hash, position, queued)
+    VALUES (?, (SELECT COALESCE(MAX(position), 0) + 1 FROM torrent_queue), ?)
+    ON CONFLICT(hash) DO UPDATE SET queued = excluded.queued`, hash, queued)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SetForceStart marks torrents as force started or not
+func (db *SQLite) SetForceStart(hashes []string, forceStart bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if forceStart {
+			_, err = tx.Exec("INSERT OR IGNORE INTO torrent_force_start (hash) VALUES (?)", hash)
+		} else {
+			_, err = tx.Exec("DELETE FROM torrent_force_start WHERE hash = ?", hash)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetForceStarted returns the hashes of all force started torrents
+func (db *SQLite) GetForceStarted() (map[string]bool, error) {
+	rows, err := db.Query("SELECT hash FROM torrent_force_start")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	forced := map[string]bool{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		forced[hash] = true
+	}
+	return forced, rows.Err()
+}
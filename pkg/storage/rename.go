@@ -0,0 +1,89 @@
+package storage
+
+// SetTorrentName sets the display name of a torrent. An empty name restores the name Tribler reports.
+func (db *SQLite) SetTorrentName(hash, name string) error {
+	if name == "" {
+		_, err := db.Exec("DELETE FROM torrent_name WHERE hash = ?", hash)
+		return err
+	}
+	_, err := db.Exec(
+		`INSERT INTO torrent_name (hash, name) VALUES (?, ?)
+    ON CONFLICT(hash) DO UPDATE SET name = excluded.name`, hash, name)
+	return err
+}
+
+// GetTorrentNames returns the display names of all renamed torrents keyed by hash
+func (db *SQLite) GetTorrentNames() (map[string]string, error) {
+	rows, err := db.Query("SELECT hash, name FROM torrent_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[string]string{}
+	for rows.Next() {
+		var hash, name string
+		if err := rows.Scan(&hash, &name); err != nil {
+			return nil, err
+		}
+		names[hash] = name
+	}
+	return names, rows.Err()
+}
+
+// SetTorrentFileNames stores the paths of renamed files of a torrent keyed by file index
+func (db *SQLite) SetTorrentFileNames(hash string, names map[int]string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for index, name := range names {
+		_, err = tx.Exec(
+			`INSERT INTO torrent_file_name (hash, file_index, name) VALUES (?, ?, ?)
+    ON CONFLICT(hash, file_index) DO UPDATE SET name = excluded.name`, hash, index, name)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetTorrentFileNames returns the paths of the renamed files of a torrent keyed by file index
+func (db *SQLite) GetTorrentFileNames(hash string) (map[int]string, error) {
+	rows, err := db.Query("SELECT file_index, name FROM torrent_file_name WHERE hash = ?", hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[int]string{}
+	for rows.Next() {
+		var index int
+		var name string
+		if err := rows.Scan(&index, &name); err != nil {
+			return nil, err
+		}
+		names[index] = name
+	}
+	return names, rows.Err()
+}
+
+// GetRenamedTorrents returns the hashes of the torrents that have renamed files
+func (db *SQLite) GetRenamedTorrents() (map[string]bool, error) {
+	rows, err := db.Query("SELECT DISTINCT hash FROM torrent_file_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	renamed := map[string]bool{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		renamed[hash] = true
+	}
+	return renamed, rows.Err()
+}
@@ -0,0 +1,32 @@
+package storage
+
+// SetTorrentSavePath sets where a torrent is moved once complete. An empty save path removes it.
+func (db *SQLite) SetTorrentSavePath(hash, savePath string) error {
+	if savePath == "" {
+		_, err := db.Exec("DELETE FROM torrent_save_path WHERE hash = ?", hash)
+		return err
+	}
+	_, err := db.Exec(
+		`INSERT INTO torrent_save_path (hash, save_path) VALUES (?, ?)
+    ON CONFLICT(hash) DO UPDATE SET save_path = excluded.save_path`, hash, savePath)
+	return err
+}
+
+// GetTorrentSavePaths returns the save paths of torrents waiting to be moved keyed by hash
+func (db *SQLite) GetTorrentSavePaths() (map[string]string, error) {
+	rows, err := db.Query("SELECT hash, save_path FROM torrent_save_path")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	savePaths := map[string]string{}
+	for rows.Next() {
+		var hash, savePath string
+		if err := rows.Scan(&hash, &savePath); err != nil {
+			return nil, err
+		}
+		savePaths[hash] = savePath
+	}
+	return savePaths, rows.Err()
+}
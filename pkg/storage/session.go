@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"time"
+)
+
+// AddSession stores a new session ID that is valid until expiresAt
+func (db *SQLite) AddSession(sid string, expiresAt time.Time) error {
+	_, err := db.Exec("INSERT INTO session (sid, expires_at) VALUES (?, ?)", sid, expiresAt.Unix())
+	return err
+}
+
+// SessionValid reports whether the session ID exists and has not expired yet
+func (db *SQLite) SessionValid(sid string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM session WHERE sid = ? AND expires_at > ?", sid, time.Now().Unix()).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RefreshSession moves the expiry of an existing session to expiresAt
+func (db *SQLite) RefreshSession(sid string, expiresAt time.Time) error {
+	_, err := db.Exec("UPDATE session SET expires_at = ? WHERE sid = ?", expiresAt.Unix(), sid)
+	return err
+}
+
+// DeleteSession removes a session, e.g. on logout
+func (db *SQLite) DeleteSession(sid string) error {
+	_, err := db.Exec("DELETE FROM session WHERE sid = ?", sid)
+	return err
+}
+
+// DeleteExpiredSessions removes all sessions that are past their expiry
+func (db *SQLite) DeleteExpiredSessions() error {
+	_, err := db.Exec("DELETE FROM session WHERE expires_at <= ?", time.Now().Unix())
+	return err
+}
@@ -0,0 +1,95 @@
+package storage
+
+// ShareLimits are the per torrent limits set through setShareLimits. Times are in minutes.
+type ShareLimits struct {
+	Hash                     string
+	RatioLimit               float64
+	SeedingTimeLimit         int
+	InactiveSeedingTimeLimit int
+}
+
+// TorrentStats are tracked by the shim as Tribler does not report them. Timestamps are unix seconds,
+// SeedingTime and ActiveTime are in seconds.
+type TorrentStats struct {
+	Hash             string
+	CompletedOn      int64
+	SeedingTime      int64
+	LastActivity     int64
+	ActiveTime       int64
+	LastSeenComplete int64
+}
+
+// SetShareLimits stores the same share limits for every hash
+func (db *SQLite) SetShareLimits(hashes []string, limits ShareLimits) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		_, err = tx.Exec(
+			`INSERT OR REPLACE INTO torrent_share_limit (hash, ratio_limit, seeding_time_limit, inactive_seeding_time_limit)
+    VALUES (?, ?, ?, ?)`, hash, limits.RatioLimit, limits.SeedingTimeLimit, limits.InactiveSeedingTimeLimit)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetShareLimits returns the share limits of every torrent that has any, keyed by hash
+func (db *SQLite) GetShareLimits() (map[string]ShareLimits, error) {
+	rows, err := db.Query("SELECT hash, ratio_limit, seeding_time_limit, inactive_seeding_time_limit FROM torrent_share_limit")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := map[string]ShareLimits{}
+	for rows.Next() {
+		var l ShareLimits
+		if err := rows.Scan(&l.Hash, &l.RatioLimit, &l.SeedingTimeLimit, &l.InactiveSeedingTimeLimit); err != nil {
+			return nil, err
+		}
+		limits[l.Hash] = l
+	}
+	return limits, rows.Err()
+}
+
+// GetTorrentStats returns the tracked statistics of every torrent keyed by hash
+func (db *SQLite) GetTorrentStats() (map[string]TorrentStats, error) {
+	rows, err := db.Query(
+		"SELECT hash, completed_on, seeding_time, last_activity, active_time, last_seen_complete FROM torrent_stats")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := map[string]TorrentStats{}
+	for rows.Next() {
+		var s TorrentStats
+		if err := rows.Scan(&s.Hash, &s.CompletedOn, &s.SeedingTime, &s.LastActivity, &s.ActiveTime, &s.LastSeenComplete); err != nil {
+			return nil, err
+		}
+		stats[s.Hash] = s
+	}
+	return stats, rows.Err()
+}
+
+// SaveTorrentStats inserts or replaces the statistics of the given torrents
+func (db *SQLite) SaveTorrentStats(stats []TorrentStats) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, s := range stats {
+		_, err = tx.Exec(
+			`INSERT OR REPLACE INTO torrent_stats (hash, completed_on, seeding_time, last_activity, active_time, last_seen_complete)
+    VALUES (?, ?, ?, ?, ?, ?)`, s.Hash, s.CompletedOn, s.SeedingTime, s.LastActivity, s.ActiveTime, s.LastSeenComplete)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
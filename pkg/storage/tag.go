@@ -0,0 +1,129 @@
+package storage
+
+// GetTags returns all tag names in the database
+func (db *SQLite) GetTags() ([]string, error) {
+	rows, err := db.Query("SELECT name FROM tag ORDER BY name ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// CreateTags adds tags that do not exist yet
+func (db *SQLite) CreateTags(tags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		_, err = tx.Exec("INSERT OR IGNORE INTO tag (name) VALUES (?)", tag)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteTags removes tags and detaches them from every torrent
+func (db *SQLite) DeleteTags(tags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		_, err = tx.Exec("DELETE FROM torrent_tag WHERE tag_id IN (SELECT id FROM tag WHERE name = ?)", tag)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM tag WHERE name = ?", tag)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// AddTorrentTags attaches tags to torrents, creating tags that do not exist yet
+func (db *SQLite) AddTorrentTags(hashes, tags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		_, err = tx.Exec("INSERT OR IGNORE INTO tag (name) VALUES (?)", tag)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		for _, hash := range hashes {
+			_, err = tx.Exec("INSERT OR IGNORE INTO torrent_tag (hash, tag_id) SELECT ?, id FROM tag WHERE name = ?", hash, tag)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// RemoveTorrentTags detaches tags from torrents. Without tags, all tags of the torrents are removed.
+func (db *SQLite) RemoveTorrentTags(hashes, tags []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if len(tags) == 0 {
+			_, err = tx.Exec("DELETE FROM torrent_tag WHERE hash = ?", hash)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+			continue
+		}
+		for _, tag := range tags {
+			_, err = tx.Exec("DELETE FROM torrent_tag WHERE hash = ? AND tag_id IN (SELECT id FROM tag WHERE name = ?)", hash, tag)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// GetTorrentTags returns the tag names of every tagged torrent keyed by hash
+func (db *SQLite) GetTorrentTags() (map[string][]string, error) {
+	rows, err := db.Query(
+		`SELECT tt.hash, t.name
+    FROM torrent_tag as tt, tag as t
+    WHERE tt.tag_id = t.id
+    ORDER BY t.name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := map[string][]string{}
+	for rows.Next() {
+		var hash, tag string
+		if err := rows.Scan(&hash, &tag); err != nil {
+			return nil, err
+		}
+		tags[hash] = append(tags[hash], tag)
+	}
+	return tags, rows.Err()
+}
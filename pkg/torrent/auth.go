@@ -0,0 +1,286 @@
+package language
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"tribler-arr-shim/pkg/logbuffer"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	shimUsernameEnv = "TRIBLER_ARR_SHIM_USERNAME"
+	shimPasswordEnv = "TRIBLER_ARR_SHIM_PASSWORD"
+	// defaultShimUsername is used when no username is configured, like the admin default of qBittorrent
+	defaultShimUsername = "admin"
+	sidCookieName       = "SID"
+	sidContextKey       = "sid"
+	bypassSidPrefix     = "bypass:"
+	// defaults of the web_ui_session_timeout, web_ui_max_auth_fail_count and web_ui_ban_duration preferences
+	defaultSessionTimeout   = time.Hour
+	defaultMaxAuthFailCount = 5
+	defaultAuthBanDuration  = time.Hour
+)
+
+// authFailures counts failed logins per client IP so that repeated failures lead to a ban
+type authFailures struct {
+	mu          sync.Mutex
+	count       map[string]int
+	bannedUntil map[string]time.Time
+}
+
+func newAuthFailures() *authFailures {
+	return &authFailures{
+		count:       map[string]int{},
+		bannedUntil: map[string]time.Time{},
+	}
+}
+
+func (a *authFailures) isBanned(ip string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	until, ok := a.bannedUntil[ip]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(a.bannedUntil, ip)
+		return false
+	}
+	return true
+}
+
+// fail counts a failed login and bans the IP for banDuration once it failed maxCount times. A maxCount of 0 disables bans.
+func (a *authFailures) fail(ip string, maxCount int, banDuration time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if maxCount <= 0 {
+		return
+	}
+	a.count[ip]++
+	if a.count[ip] >= maxCount {
+		logbuffer.Info("Banning %s after %d failed login attempts", ip, a.count[ip])
+		a.bannedUntil[ip] = time.Now().Add(banDuration)
+		logbuffer.Peers.Add(ip, true, "Too many failed authentication attempts")
+		delete(a.count, ip)
+	}
+}
+
+func (a *authFailures) reset(ip string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.count, ip)
+}
+
+// credentials are the username and password clients have to log in with
+type credentials struct {
+	username string
+	password string
+}
+
+// loadCredentials reads the credentials from the environment. Like qBittorrent, the username defaults to
+// admin, and when no password is set a random one is generated for this run and printed once to the console.
+func loadCredentials() credentials {
+	creds := credentials{
+		username: os.Getenv(shimUsernameEnv),
+		password: os.Getenv(shimPasswordEnv),
+	}
+	if creds.username == "" {
+		creds.username = defaultShimUsername
+	}
+	if creds.password == "" {
+		password, err := generateSessionID()
+		if err != nil {
+			log.Fatal("Error generating a temporary password: ", err)
+		}
+		creds.password = password
+		logbuffer.Warning("%s is not set, a temporary password was generated for this session. Set it to keep logins working across restarts.", shimPasswordEnv)
+		// printed to the console only, so that it does not end up in the log the API serves
+		log.Printf("Temporary password for user %s: %s", creds.username, creds.password)
+	}
+	return creds
+}
+
+// check compares the posted credentials with the configured ones
+func (c credentials) check(username, password string) bool {
+	usernameOk := subtle.ConstantTimeCompare([]byte(username), []byte(c.username)) == 1
+	passwordOk := subtle.ConstantTimeCompare([]byte(password), []byte(c.password)) == 1
+	return usernameOk && passwordOk
+}
+
+// parseSubnets parses a comma or newline separated list of subnets in CIDR notation
+func parseSubnets(list string) ([]*net.IPNet, error) {
+	subnets := []*net.IPNet{}
+	for _, value := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' }) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		subnets = append(subnets, subnet)
+	}
+	return subnets, nil
+}
+
+// authBypassed tells whether clients from ip may use the API without logging in
+func authBypassed(ip string, preferences AppPreferences) bool {
+	client := net.ParseIP(ip)
+	if client == nil {
+		return false
+	}
+	if preferences.BypassLocalAuth && client.IsLoopback() {
+		return true
+	}
+	if !preferences.BypassAuthSubnetWhitelistEnabled {
+		return false
+	}
+	subnets, err := parseSubnets(preferences.BypassAuthSubnetWhitelist)
+	if err != nil {
+		logbuffer.Warning("Error parsing bypass_auth_subnet_whitelist: %+v", err)
+		return false
+	}
+	for _, subnet := range subnets {
+		if subnet.Contains(client) {
+			return true
+		}
+	}
+	return false
+}
+
+func sessionTimeout(preferences AppPreferences) time.Duration {
+	if preferences.WebUISessionTimeout <= 0 {
+		return defaultSessionTimeout
+	}
+	return time.Duration(preferences.WebUISessionTimeout) * time.Second
+}
+
+// generateSessionID returns a random 32 character session ID
+func generateSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// LoginHandler authenticates the client and hands out a session ID cookie
+func (h *Handler) LoginHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if h.authFailures.isBanned(ip) {
+			c.String(http.StatusForbidden, "Your IP address has been banned after too many failed authentication attempts.")
+			return
+		}
+
+		preferences, err := h.loadPreferences()
+		if err != nil {
+			handleInternalError(c, "Failed to load preferences", err)
+			return
+		}
+
+		if !h.credentials.check(c.PostForm("username"), c.PostForm("password")) {
+			logbuffer.Warning("Failed login attempt from %s", ip)
+			h.authFailures.fail(ip, preferences.WebUIMaxAuthFailCount, time.Duration(preferences.WebUIBanDuration)*time.Second)
+			c.String(http.StatusOK, "Fails.")
+			return
+		}
+		h.authFailures.reset(ip)
+
+		sid, err := generateSessionID()
+		if err != nil {
+			handleInternalError(c, "Failed to generate session ID", err)
+			return
+		}
+
+		err = h.storeSessionID(sid, sessionTimeout(preferences))
+		if err != nil {
+			handleInternalError(c, "Failed to store session ID", err)
+			return
+		}
+		c.SetCookie(sidCookieName, sid, 0, "/", "", false, true)
+		session := sessions.Default(c)
+		session.Set(sidCookieName, sid)
+		session.Save()
+
+		c.String(http.StatusOK, "Ok.")
+	}
+}
+
+// LogoutHandler invalidates the session of the client
+func (h *Handler) LogoutHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sid := c.GetString(sidContextKey)
+		if err := h.DB.DeleteSession(sid); err != nil {
+			handleInternalError(c, "Failed to delete session", err)
+			return
+		}
+		h.sync.forget(sid)
+		c.SetCookie(sidCookieName, "", -1, "/", "", false, true)
+		c.Status(http.StatusOK)
+	}
+}
+
+// AuthMiddleware rejects requests without a valid session ID with 403 like qBittorrent does, unless
+// the client is allowed to bypass authentication
+func (h *Handler) AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		preferences, err := h.loadPreferences()
+		if err != nil {
+			handleInternalError(c, "Failed to load preferences", err)
+			c.Abort()
+			return
+		}
+		// ClientIP only honours X-Forwarded-For from trusted proxies, so it cannot be spoofed
+		if ip := c.ClientIP(); authBypassed(ip, preferences) {
+			// bypassed clients have no session, but still need their own sync/maindata snapshots
+			c.Set(sidContextKey, bypassSidPrefix+ip)
+			c.Next()
+			return
+		}
+
+		sid, err := c.Cookie(sidCookieName)
+		if err != nil || sid == "" {
+			c.String(http.StatusForbidden, "Forbidden")
+			c.Abort()
+			return
+		}
+
+		valid, err := h.DB.SessionValid(sid)
+		if err != nil {
+			handleInternalError(c, "Failed to validate session", err)
+			c.Abort()
+			return
+		}
+		if !valid {
+			c.String(http.StatusForbidden, "Forbidden")
+			c.Abort()
+			return
+		}
+
+		// sessions expire after a period of inactivity, so every request extends it
+		if err := h.DB.RefreshSession(sid, time.Now().Add(sessionTimeout(preferences))); err != nil {
+			logbuffer.Warning("Error refreshing session: %+v", err)
+		}
+		c.Set(sidContextKey, sid)
+		c.Next()
+	}
+}
+
+func (h *Handler) storeSessionID(sid string, timeout time.Duration) error {
+	if err := h.DB.DeleteExpiredSessions(); err != nil {
+		logbuffer.Warning("Error deleting expired sessions: %+v", err)
+	}
+	return h.DB.AddSession(sid, time.Now().Add(timeout))
+}
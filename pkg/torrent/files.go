@@ -0,0 +1,97 @@
+package language
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// qBittorrent file priorities. Tribler can only include or skip a file, so every priority above
+// skipped includes it and included files are reported as normal.
+const (
+	filePrioritySkipped = 0
+	filePriorityNormal  = 1
+	filePriorityHigh    = 6
+	filePriorityMaximal = 7
+)
+
+func filePriority(file tribler.Files) int {
+	if file.Included {
+		return filePriorityNormal
+	}
+	return filePrioritySkipped
+}
+
+func validFilePriority(priority int) bool {
+	switch priority {
+	case filePrioritySkipped, filePriorityNormal, filePriorityHigh, filePriorityMaximal:
+		return true
+	}
+	return false
+}
+
+// SetFilePriority includes or skips files of a torrent by updating the files Tribler selected
+func (h *Handler) SetFilePriority() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash := strings.ToLower(c.PostForm("hash"))
+		priority, err := strconv.Atoi(c.PostForm("priority"))
+		if err != nil || !validFilePriority(priority) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid priority"})
+			return
+		}
+		ids := []int{}
+		for _, value := range strings.Split(c.PostForm("id"), "|") {
+			id, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid file id"})
+				return
+			}
+			ids = append(ids, id)
+		}
+
+		torrentFiles, err := h.downloadFiles(hash)
+		if err != nil {
+			logbuffer.Warning("Error getting files of %s: %+v", hash, err)
+			c.JSON(http.StatusNotFound, gin.H{"message": "Torrent not found"})
+			return
+		}
+		if len(torrentFiles.Files) == 0 {
+			c.JSON(http.StatusConflict, gin.H{"message": "Torrent metadata hasn't downloaded yet"})
+			return
+		}
+
+		included := map[int]bool{}
+		for _, file := range torrentFiles.Files {
+			included[file.Index] = file.Included
+		}
+		for _, id := range ids {
+			if _, ok := included[id]; !ok {
+				c.JSON(http.StatusConflict, gin.H{"message": "File id " + strconv.Itoa(id) + " not found"})
+				return
+			}
+			included[id] = priority != filePrioritySkipped
+		}
+
+		selected := []int{}
+		for _, file := range torrentFiles.Files {
+			if included[file.Index] {
+				selected = append(selected, file.Index)
+			}
+		}
+		// Tribler treats an empty selection as every file selected
+		if len(selected) == 0 {
+			c.JSON(http.StatusConflict, gin.H{"message": "At least one file has to be included"})
+			return
+		}
+		err = tribler.SetSelectedFiles(hash, selected)
+		if err != nil {
+			handleInternalError(c, "Failed to set file priority", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "File priority set"})
+	}
+}
@@ -0,0 +1,204 @@
+package language
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// infoQuery holds the filter, sort and paging parameters of /torrents/info
+type infoQuery struct {
+	filter      string
+	category    string
+	anyCategory bool
+	tag         string
+	anyTag      bool
+	hashes      map[string]bool
+	sort        string
+	reverse     bool
+	limit       int
+	offset      int
+}
+
+func isDownloadingState(state string) bool {
+	switch state {
+	case "downloading", "metaDL", "forcedMetaDL", "stalledDL", "checkingDL", "pausedDL", "stoppedDL", "queuedDL", "forcedDL", "allocating":
+		return true
+	}
+	return false
+}
+
+func isUploadingState(state string) bool {
+	switch state {
+	case "uploading", "stalledUP", "checkingUP", "queuedUP", "forcedUP":
+		return true
+	}
+	return false
+}
+
+func isCompletedState(state string) bool {
+	switch state {
+	case "uploading", "stalledUP", "checkingUP", "pausedUP", "stoppedUP", "queuedUP", "forcedUP":
+		return true
+	}
+	return false
+}
+
+func isPausedState(state string) bool {
+	switch state {
+	case "pausedDL", "pausedUP", "stoppedDL", "stoppedUP":
+		return true
+	}
+	return false
+}
+
+func isStalledState(state string) bool {
+	return state == "stalledDL" || state == "stalledUP"
+}
+
+func isCheckingState(state string) bool {
+	switch state {
+	case "checkingDL", "checkingUP", "checkingResumeData":
+		return true
+	}
+	return false
+}
+
+func isErroredState(state string) bool {
+	return state == "error" || state == "missingFiles"
+}
+
+// isActive follows qBittorrent: a torrent is active while it transfers data or is about to
+func isActive(torrent Torrent) bool {
+	switch torrent.State {
+	case "stalledDL":
+		return torrent.Upspeed > 0
+	case "metaDL", "forcedMetaDL", "downloading", "forcedDL", "uploading", "forcedUP", "moving":
+		return true
+	}
+	return false
+}
+
+func matchesFilter(filter string, torrent Torrent) bool {
+	switch filter {
+	case "", "all":
+		return true
+	case "downloading":
+		return isDownloadingState(torrent.State)
+	case "seeding":
+		return isUploadingState(torrent.State)
+	case "completed":
+		return isCompletedState(torrent.State)
+	case "paused", "stopped":
+		return isPausedState(torrent.State)
+	case "resumed", "running":
+		return !isPausedState(torrent.State)
+	case "active":
+		return isActive(torrent)
+	case "inactive":
+		return !isActive(torrent)
+	case "stalled":
+		return isStalledState(torrent.State)
+	case "stalled_uploading":
+		return torrent.State == "stalledUP"
+	case "stalled_downloading":
+		return torrent.State == "stalledDL"
+	case "checking":
+		return isCheckingState(torrent.State)
+	case "moving":
+		return torrent.State == "moving"
+	case "errored":
+		return isErroredState(torrent.State)
+	}
+	return false
+}
+
+func hasTag(torrent Torrent, tag string) bool {
+	if tag == "" {
+		return torrent.Tags == ""
+	}
+	for _, t := range strings.Split(torrent.Tags, ",") {
+		if strings.TrimSpace(t) == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func filterTorrents(torrents []Torrent, query infoQuery) []Torrent {
+	filtered := []Torrent{}
+	for _, torrent := range torrents {
+		if !matchesFilter(query.filter, torrent) {
+			continue
+		}
+		if !query.anyCategory && torrent.Category != query.category {
+			continue
+		}
+		if !query.anyTag && !hasTag(torrent, query.tag) {
+			continue
+		}
+		if len(query.hashes) > 0 && !query.hashes[torrent.Hash] {
+			continue
+		}
+		filtered = append(filtered, torrent)
+	}
+	return filtered
+}
+
+// torrentFieldIndex maps the JSON names of Torrent fields to their index so that any field can be sorted on
+func torrentFieldIndex(name string) (int, bool) {
+	t := reflect.TypeOf(Torrent{})
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("json"), ",")[0] == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func lessValue(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return strings.ToLower(a.String()) < strings.ToLower(b.String())
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+	return false
+}
+
+func sortTorrents(torrents []Torrent, field string, reverse bool) {
+	index, ok := torrentFieldIndex(field)
+	if !ok {
+		return
+	}
+	sort.SliceStable(torrents, func(i, j int) bool {
+		a := reflect.ValueOf(torrents[i]).Field(index)
+		b := reflect.ValueOf(torrents[j]).Field(index)
+		if reverse {
+			return lessValue(b, a)
+		}
+		return lessValue(a, b)
+	})
+}
+
+// paginate applies offset and limit. A negative offset counts from the end, a limit of 0 means no limit.
+func paginate(torrents []Torrent, offset, limit int) []Torrent {
+	if offset < 0 {
+		offset = len(torrents) + offset
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	if offset >= len(torrents) {
+		return []Torrent{}
+	}
+	torrents = torrents[offset:]
+	if limit > 0 && limit < len(torrents) {
+		torrents = torrents[:limit]
+	}
+	return torrents
+}
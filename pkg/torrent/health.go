@@ -0,0 +1,107 @@
+package language
+
+import (
+	"sync"
+	"time"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/tribler"
+)
+
+// healthInterval is how often the trackers of a torrent are asked about its swarm
+const healthInterval = 10 * time.Minute
+
+// swarmCount is the largest swarm a tracker or the DHT reported for a torrent
+type swarmCount struct {
+	seeders  int
+	leechers int
+	checked  time.Time
+}
+
+// swarmHealth caches the swarm sizes Tribler's health checks report. Health checks wait for the trackers to
+// answer, so they run in the background and requests only read the cache.
+type swarmHealth struct {
+	mu         sync.Mutex
+	counts     map[string]swarmCount
+	refreshing bool
+}
+
+func newSwarmHealth() *swarmHealth {
+	return &swarmHealth{counts: map[string]swarmCount{}}
+}
+
+// get returns the last reported swarm of a torrent, zero if it was not checked yet
+func (s *swarmHealth) get(hash string) swarmCount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[hash]
+}
+
+// refresh checks the downloads whose swarm was not checked within healthInterval in the background, unless
+// the previous refresh is still running. Torrents that are gone are forgotten.
+func (s *swarmHealth) refresh(downloads []tribler.Download) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refreshing {
+		return
+	}
+
+	present := map[string]bool{}
+	stale := []string{}
+	for _, download := range downloads {
+		present[download.Infohash] = true
+		if time.Since(s.counts[download.Infohash].checked) > healthInterval {
+			stale = append(stale, download.Infohash)
+		}
+	}
+	for hash := range s.counts {
+		if !present[hash] {
+			delete(s.counts, hash)
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	s.refreshing = true
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			s.refreshing = false
+			s.mu.Unlock()
+		}()
+		for _, hash := range stale {
+			count, known, err := checkSwarm(hash)
+			if err != nil {
+				logbuffer.Warning("Error checking the swarm of %s: %+v", hash, err)
+			}
+			s.mu.Lock()
+			if !known {
+				// keep the last known counts, but wait for the next interval before asking again
+				count = s.counts[hash]
+				count.checked = time.Now()
+			}
+			s.counts[hash] = count
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// checkSwarm asks Tribler for the health of a torrent and keeps the largest counts any source reported. It
+// tells whether any source answered; when none did, the swarm is unknown rather than empty.
+func checkSwarm(hash string) (swarmCount, bool, error) {
+	health, err := tribler.GetTorrentHealth(hash)
+	if err != nil {
+		return swarmCount{}, false, err
+	}
+	count := swarmCount{checked: time.Now()}
+	known := false
+	for _, source := range health {
+		if source.Error != "" {
+			continue
+		}
+		known = true
+		count.seeders = max(count.seeders, source.Seeders)
+		count.leechers = max(count.leechers, source.Leechers)
+	}
+	return count, known, nil
+}
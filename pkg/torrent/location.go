@@ -0,0 +1,104 @@
+package language
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// moveTimeout is how long a download is reported as moving at most, in case the old data never disappears
+const moveTimeout = time.Hour
+
+type move struct {
+	from    string
+	to      string
+	started time.Time
+}
+
+// moves keeps track of the downloads Tribler is moving to another directory. Tribler reports the new destination
+// as soon as a move starts, so a move is considered done once the data is gone from the old directory, or after
+// moveTimeout.
+type moves struct {
+	mu     sync.Mutex
+	active map[string]move
+}
+
+func newMoves() *moves {
+	return &moves{active: map[string]move{}}
+}
+
+func (m *moves) start(hash, from, to string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.active[hash] = move{from: from, to: to, started: time.Now()}
+}
+
+// target returns where a download is being moved to, if it is being moved
+func (m *moves) target(download tribler.Download) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	active, ok := m.active[download.Infohash]
+	if !ok {
+		return "", false
+	}
+	if download.Status == "STOPPED_ON_ERROR" || time.Since(active.started) > moveTimeout {
+		delete(m.active, download.Infohash)
+		return "", false
+	}
+	if filepath.Clean(download.Destination) == filepath.Clean(active.to) {
+		if _, err := os.Stat(filepath.Join(active.from, download.Name)); err != nil {
+			delete(m.active, download.Infohash)
+			return "", false
+		}
+	}
+	return active.to, true
+}
+
+// moveTorrent asks Tribler to move the data of a download to location. The download then stays there, so
+// the save path it was waiting for in the temp path is dropped.
+func (h *Handler) moveTorrent(hash, location string) error {
+	download, err := tribler.GetDownload(hash)
+	if err != nil {
+		return err
+	}
+	if filepath.Clean(download.Destination) != filepath.Clean(location) {
+		logbuffer.Info("Moving %s to %s", hash, location)
+		err = tribler.MoveDownload(hash, location)
+		if err != nil {
+			return err
+		}
+		h.moves.start(hash, download.Destination, location)
+	}
+	return h.DB.SetTorrentSavePath(hash, "")
+}
+
+// SetLocation moves the data of torrents to a new directory
+func (h *Handler) SetLocation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		location := strings.TrimSpace(c.PostForm("location"))
+		if location == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Save path is empty"})
+			return
+		}
+		hashes, err := h.resolveHashes(c.PostForm("hashes"))
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
+		}
+		for _, hash := range hashes {
+			err = h.moveTorrent(hash, location)
+			if err != nil {
+				handleInternalError(c, "Failed to move "+hash, err)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Torrent location set"})
+	}
+}
@@ -0,0 +1,137 @@
+package language
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"tribler-arr-shim/pkg/storage"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeTribler serves a single download and applies move_storage requests to it
+type fakeTribler struct {
+	mu       sync.Mutex
+	download tribler.Download
+}
+
+func (f *fakeTribler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/downloads":
+		json.NewEncoder(w).Encode(tribler.DownloadsResponse{Downloads: []tribler.Download{f.download}})
+	case r.Method == http.MethodPatch && r.URL.Path == "/downloads/"+f.download.Infohash:
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["state"] == "move_storage" {
+			f.download.Destination = body["dest_dir"].(string)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"modified": true})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeTribler) destination() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.download.Destination
+}
+
+func (f *fakeTribler) complete() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.download.Progress = 1
+}
+
+func newTestHandler(t *testing.T, fake *fakeTribler) *Handler {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	t.Setenv("TRIBLER_API_ENDPOINT", server.URL)
+	t.Setenv("TRIBLER_API_KEY", "key")
+
+	db, err := storage.New(filepath.Join(t.TempDir(), "database.db"), filepath.Join("..", "..", "scripts", "init_db.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewHandler(db)
+}
+
+func post(handler gin.HandlerFunc, form url.Values) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler(c)
+	return recorder
+}
+
+// A torrent downloading into the temp path that is moved by the user must stay where it was moved to once
+// it completes, instead of going to the save path it was added with.
+func TestMoveReplacesPendingSavePath(t *testing.T) {
+	tests := []struct {
+		name string
+		move func(t *testing.T, h *Handler, hash string)
+	}{
+		{"setLocation", func(t *testing.T, h *Handler, hash string) {
+			response := post(h.SetLocation(), url.Values{"hashes": {hash}, "location": {"/chosen"}})
+			if response.Code != http.StatusOK {
+				t.Fatalf("setLocation = %d %s", response.Code, response.Body)
+			}
+		}},
+		{"setCategory", func(t *testing.T, h *Handler, hash string) {
+			t.Setenv("MOVE_ON_CATEGORY_CHANGE", "true")
+			if err := h.DB.AddCategory("tv", "/chosen"); err != nil {
+				t.Fatal(err)
+			}
+			response := post(h.SetCategory(), url.Values{"hashes": {hash}, "category": {"tv"}})
+			if response.Code != http.StatusOK {
+				t.Fatalf("setCategory = %d %s", response.Code, response.Body)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash := "aaaa"
+			fake := &fakeTribler{download: tribler.Download{Infohash: hash, Name: "First", Destination: "/temp", Progress: 0.5, Status: "DOWNLOADING"}}
+			h := newTestHandler(t, fake)
+			// added while temp_path_enabled was set
+			if err := h.DB.SetTorrentSavePath(hash, "/save"); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.move(t, h, hash)
+			if got := fake.destination(); got != "/chosen" {
+				t.Fatalf("destination after moving = %q, want /chosen", got)
+			}
+
+			fake.complete()
+			downloads, err := tribler.GetDownloads()
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.moveCompleted(downloads.Downloads)
+
+			if got := fake.destination(); got != "/chosen" {
+				t.Errorf("destination after completing = %q, want /chosen", got)
+			}
+			savePaths, err := h.DB.GetTorrentSavePaths()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if savePath, ok := savePaths[hash]; ok {
+				t.Errorf("pending save path = %q, want none", savePath)
+			}
+		})
+	}
+}
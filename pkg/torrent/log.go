@@ -0,0 +1,47 @@
+package language
+
+import (
+	"net/http"
+	"strconv"
+	"tribler-arr-shim/pkg/logbuffer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lastKnownID parses the last_known_id query parameter, -1 when missing
+func lastKnownID(c *gin.Context) int {
+	id, err := strconv.Atoi(c.DefaultQuery("last_known_id", "-1"))
+	if err != nil {
+		return -1
+	}
+	return id
+}
+
+// GetMainLog retrieves the shim's log messages
+func (h *Handler) GetMainLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		types := 0
+		for _, filter := range []struct {
+			param       string
+			messageType int
+		}{
+			{"normal", logbuffer.TypeNormal},
+			{"info", logbuffer.TypeInfo},
+			{"warning", logbuffer.TypeWarning},
+			{"critical", logbuffer.TypeCritical},
+		} {
+			// like qBittorrent, every type is included unless it is excluded
+			if c.DefaultQuery(filter.param, "true") != "false" {
+				types |= filter.messageType
+			}
+		}
+		c.JSON(http.StatusOK, logbuffer.Main.Entries(lastKnownID(c), types))
+	}
+}
+
+// GetPeerLog retrieves the clients the shim banned
+func (h *Handler) GetPeerLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, logbuffer.Peers.Entries(lastKnownID(c)))
+	}
+}
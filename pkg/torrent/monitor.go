@@ -0,0 +1,118 @@
+package language
+
+import (
+	"time"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/storage"
+	"tribler-arr-shim/pkg/tribler"
+)
+
+// MonitorInterval is how often the monitor polls Tribler
+const MonitorInterval = 30 * time.Second
+
+// isRunning reports whether Tribler is working on a download, as opposed to it being stopped
+func isRunning(download tribler.Download) bool {
+	return download.Status != "STOPPED" && download.Status != "STOPPED_ON_ERROR"
+}
+
+// RunMonitor periodically tracks torrent statistics that Tribler does not report, checks the swarms of torrents,
+// moves completed torrents out of the temp path, enforces share limits and applies the download queue.
+// It never returns, so run it in its own goroutine.
+func (h *Handler) RunMonitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.monitor()
+	}
+}
+
+func (h *Handler) monitor() {
+	h.monitorMu.Lock()
+	defer h.monitorMu.Unlock()
+
+	downloads, err := tribler.GetDownloads()
+	if err != nil {
+		logbuffer.Warning("Monitor: error getting downloads: %+v", err)
+		return
+	}
+
+	stats, err := h.updateTorrentStats(downloads.Downloads)
+	if err != nil {
+		logbuffer.Warning("Monitor: error updating torrent stats: %+v", err)
+		return
+	}
+	h.health.refresh(downloads.Downloads)
+	h.moveCompleted(downloads.Downloads)
+	if h.enforceShareLimits(downloads.Downloads, stats) {
+		// the queue has to see the torrents that were just paused or removed
+		downloads, err = tribler.GetDownloads()
+		if err != nil {
+			logbuffer.Warning("Monitor: error getting downloads: %+v", err)
+			return
+		}
+	}
+	h.applyQueue(downloads.Downloads)
+}
+
+// updateTorrentStats adds the time since the last run to the active time of running torrents and the seeding
+// time of seeding torrents, and records completion, activity and when a complete copy was last seen
+func (h *Handler) updateTorrentStats(downloads []tribler.Download) (map[string]storage.TorrentStats, error) {
+	stats, err := h.DB.GetTorrentStats()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var elapsed int64
+	if !h.lastMonitor.IsZero() {
+		elapsed = int64(now.Sub(h.lastMonitor).Seconds())
+	}
+	h.lastMonitor = now
+
+	updated := []storage.TorrentStats{}
+	for _, download := range downloads {
+		s, ok := stats[download.Infohash]
+		if !ok {
+			s = storage.TorrentStats{Hash: download.Infohash, LastActivity: now.Unix()}
+		}
+		if download.Progress >= 1 {
+			if s.CompletedOn == 0 {
+				s.CompletedOn = now.Unix()
+			}
+			if isRunning(download) {
+				s.SeedingTime += elapsed
+			}
+		}
+		if isRunning(download) {
+			s.ActiveTime += elapsed
+		}
+		if download.Progress >= 1 || download.NumSeeds > 0 {
+			s.LastSeenComplete = now.Unix()
+		}
+		if download.SpeedDown > 0 || download.SpeedUp > 0 {
+			s.LastActivity = now.Unix()
+		}
+		stats[download.Infohash] = s
+		updated = append(updated, s)
+	}
+
+	return stats, h.DB.SaveTorrentStats(updated)
+}
+
+// moveCompleted moves torrents that were downloaded into the temp path to their save path once complete
+func (h *Handler) moveCompleted(downloads []tribler.Download) {
+	savePaths, err := h.DB.GetTorrentSavePaths()
+	if err != nil {
+		logbuffer.Warning("Monitor: error getting save paths: %+v", err)
+		return
+	}
+	for _, download := range downloads {
+		savePath, ok := savePaths[download.Infohash]
+		if !ok || download.Progress < 1 {
+			continue
+		}
+		if err := h.moveTorrent(download.Infohash, savePath); err != nil {
+			logbuffer.Warning("Monitor: error moving %s to %s: %+v", download.Infohash, savePath, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package language
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TorrentPeer is a peer of /sync/torrentPeers
+type TorrentPeer struct {
+	Client      string  `json:"client"`
+	Connection  string  `json:"connection"`
+	Country     string  `json:"country"`
+	CountryCode string  `json:"country_code"`
+	DlSpeed     int     `json:"dl_speed"`
+	Downloaded  int     `json:"downloaded"`
+	Files       string  `json:"files"`
+	Flags       string  `json:"flags"`
+	FlagsDesc   string  `json:"flags_desc"`
+	IP          string  `json:"ip"`
+	Port        int     `json:"port"`
+	Progress    float64 `json:"progress"`
+	Relevance   float64 `json:"relevance"`
+	UpSpeed     int     `json:"up_speed"`
+	Uploaded    int     `json:"uploaded"`
+}
+
+// libtorrent connection types as reported by Tribler
+const (
+	connectionBitTorrent = 0
+	connectionUTP        = 1
+	connectionWebSeed    = 2
+)
+
+// peerFlags returns qBittorrent's flags of a peer and their descriptions
+func peerFlags(peer tribler.Peer) (string, string) {
+	type flag struct {
+		set  bool
+		flag string
+		desc string
+	}
+	flags := []flag{
+		{peer.Dinterested && !peer.Dchoked, "D", "Interested (local) and unchoked (peer)"},
+		{peer.Dinterested && peer.Dchoked, "d", "Interested (local) and choked (peer)"},
+		{peer.Uinterested && !peer.Uchoked, "U", "Interested (peer) and unchoked (local)"},
+		{peer.Uinterested && peer.Uchoked, "u", "Interested (peer) and choked (local)"},
+		{peer.Optimistic, "O", "Optimistic unchoke"},
+		{peer.Snubbed, "S", "Peer snubbed"},
+		{peer.Direction == "R", "I", "Incoming connection"},
+		{peer.PexReceived, "X", "Peer from PEX"},
+		{peer.ConnectionType == connectionUTP, "P", "μTP"},
+	}
+	set := []string{}
+	descs := []string{}
+	for _, f := range flags {
+		if f.set {
+			set = append(set, f.flag)
+			descs = append(descs, f.flag+" = "+f.desc)
+		}
+	}
+	return strings.Join(set, " "), strings.Join(descs, "\n")
+}
+
+func connectionName(connectionType int) string {
+	switch connectionType {
+	case connectionUTP:
+		return "μTP"
+	case connectionWebSeed:
+		return "Web"
+	}
+	return "BT"
+}
+
+// ConvertTriblerPeers converts the peers of a download keyed by ip:port
+func ConvertTriblerPeers(peers tribler.Peers) map[string]TorrentPeer {
+	converted := map[string]TorrentPeer{}
+	for _, peer := range peers {
+		flags, flagsDesc := peerFlags(peer)
+		converted[net.JoinHostPort(peer.IP, strconv.Itoa(peer.Port))] = TorrentPeer{
+			Client:     peer.ExtendedVersion,
+			Connection: connectionName(peer.ConnectionType),
+			DlSpeed:    int(peer.Downrate),
+			Downloaded: int(peer.Dtotal),
+			Flags:      flags,
+			FlagsDesc:  flagsDesc,
+			IP:         peer.IP,
+			Port:       peer.Port,
+			Progress:   peer.Completed,
+			UpSpeed:    int(peer.Uprate),
+			Uploaded:   int(peer.Utotal),
+		}
+	}
+	return converted
+}
+
+// GetTorrentPeers implements /sync/torrentPeers. Like maindata, a client only receives what changed
+// since the rid it passes.
+func (h *Handler) GetTorrentPeers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash := strings.ToLower(c.Query("hash"))
+		rid, _ := strconv.Atoi(c.Query("rid"))
+
+		download, err := tribler.GetDownloadWithPeers(hash)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Torrent not found"})
+			return
+		}
+
+		preferences, err := h.loadPreferences()
+		if err != nil {
+			handleInternalError(c, "Failed to load preferences", err)
+			return
+		}
+
+		current := &syncSnapshot{peers: map[string]map[string]interface{}{}}
+		for id, peer := range ConvertTriblerPeers(download.Peers) {
+			current.peers[id] = toMap(peer)
+		}
+		previous := h.peerSync.swap(c.GetString(sidContextKey)+"/"+hash, current, sessionTimeout(preferences))
+
+		if rid == 0 || previous == nil || previous.rid != rid {
+			c.JSON(http.StatusOK, gin.H{
+				"rid":         current.rid,
+				"full_update": true,
+				"show_flags":  true,
+				"peers":       current.peers,
+			})
+			return
+		}
+
+		response := gin.H{"rid": current.rid, "show_flags": true}
+		changed, removed := diffObjects(previous.peers, current.peers)
+		if len(changed) > 0 {
+			response["peers"] = changed
+		}
+		if len(removed) > 0 {
+			response["peers_removed"] = removed
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,102 @@
+package language
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// preferenceKeys returns the JSON names of all AppPreferences fields
+func preferenceKeys() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(AppPreferences{})
+	for i := 0; i < t.NumField(); i++ {
+		keys[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = true
+	}
+	return keys
+}
+
+// loadPreferences returns the default preferences overridden by the ones stored through setPreferences
+func (h *Handler) loadPreferences() (AppPreferences, error) {
+	preferences := defaultAppPreferences
+	// read here rather than in defaultAppPreferences, which is initialised before the .env file is loaded
+	preferences.SavePath = os.Getenv("TRIBLER_DOWNLOAD_DIR")
+
+	stored, err := h.DB.GetPreferences()
+	if err != nil {
+		return preferences, err
+	}
+	values := map[string]json.RawMessage{}
+	for key, value := range stored {
+		values[key] = json.RawMessage(value)
+	}
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return preferences, err
+	}
+	err = json.Unmarshal(raw, &preferences)
+	return preferences, err
+}
+
+// SetAppPreferences stores the preferences posted as the json form field. Unknown keys are ignored.
+func (h *Handler) SetAppPreferences() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var changes map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(c.PostForm("json")), &changes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid json"})
+			return
+		}
+
+		// decode the changes into the preferences to reject values of the wrong type before storing them
+		preferences, err := h.loadPreferences()
+		if err != nil {
+			handleInternalError(c, "Failed to load preferences", err)
+			return
+		}
+		raw, _ := json.Marshal(changes)
+		if err := json.Unmarshal(raw, &preferences); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid preference value"})
+			return
+		}
+
+		// Tribler always saves multi-file torrents into a folder named after the torrent
+		if !preferences.CreateSubfolderEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "create_subfolder_enabled cannot be disabled with Tribler"})
+			return
+		}
+		if _, err := parseSubnets(preferences.BypassAuthSubnetWhitelist); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid bypass_auth_subnet_whitelist"})
+			return
+		}
+
+		keys := preferenceKeys()
+		stored := map[string]string{}
+		libtorrent := map[string]interface{}{}
+		for key, value := range changes {
+			if mapping, ok := triblerPreferences[key]; ok {
+				mapping.write(preferences, libtorrent)
+				continue
+			}
+			if keys[key] {
+				stored[key] = string(value)
+			}
+		}
+		if len(libtorrent) > 0 {
+			err = tribler.UpdateSettings(map[string]interface{}{"libtorrent": libtorrent})
+			if err != nil {
+				handleInternalError(c, "Failed to update Tribler settings", err)
+				return
+			}
+		}
+		if err := h.DB.SetPreferences(stored); err != nil {
+			handleInternalError(c, "Failed to store preferences", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Preferences set"})
+	}
+}
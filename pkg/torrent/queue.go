@@ -0,0 +1,315 @@
+package language
+
+import (
+	"net/http"
+	"sort"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/storage"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queueOrder returns the queue entries of the current downloads in queue order. Downloads the queue
+// does not know yet are appended in the order they were added to Tribler.
+func (h *Handler) queueOrder(downloads []tribler.Download) ([]storage.QueueEntry, error) {
+	stored, err := h.DB.GetQueue()
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[string]bool{}
+	for _, download := range downloads {
+		current[download.Infohash] = true
+	}
+	known := map[string]bool{}
+	entries := []storage.QueueEntry{}
+	for _, entry := range stored {
+		if current[entry.Hash] {
+			known[entry.Hash] = true
+			entries = append(entries, entry)
+		}
+	}
+
+	added := []tribler.Download{}
+	for _, download := range downloads {
+		if !known[download.Infohash] {
+			added = append(added, download)
+		}
+	}
+	if len(added) == 0 {
+		return entries, nil
+	}
+	sort.SliceStable(added, func(i, j int) bool { return added[i].TimeAdded < added[j].TimeAdded })
+	for _, download := range added {
+		entries = append(entries, storage.QueueEntry{Hash: download.Infohash})
+	}
+	return entries, h.DB.SaveQueueOrder(entryHashes(entries))
+}
+
+func entryHashes(entries []storage.QueueEntry) []string {
+	hashes := []string{}
+	for _, entry := range entries {
+		hashes = append(hashes, entry.Hash)
+	}
+	return hashes
+}
+
+// withinLimit reports whether another active torrent fits in a max_active_* limit, where -1 means no limit
+func withinLimit(active, max int) bool {
+	return max < 0 || active < max
+}
+
+// applyQueue starts and stops Tribler downloads so that no more than max_active_downloads, max_active_uploads
+// and max_active_torrents are running. Torrents the user paused and force started torrents are left alone.
+func (h *Handler) applyQueue(downloads []tribler.Download) {
+	preferences, err := h.loadPreferences()
+	if err != nil {
+		logbuffer.Warning("Error loading preferences: %+v", err)
+		return
+	}
+	entries, err := h.queueOrder(downloads)
+	if err != nil {
+		logbuffer.Warning("Error getting queue: %+v", err)
+		return
+	}
+	forced, err := h.DB.GetForceStarted()
+	if err != nil {
+		logbuffer.Warning("Error getting force started torrents: %+v", err)
+		return
+	}
+	// Tribler cannot find the files of torrents with renamed files, so they are never started
+	renamed, err := h.DB.GetRenamedTorrents()
+	if err != nil {
+		logbuffer.Warning("Error getting renamed torrents: %+v", err)
+		return
+	}
+	byHash := map[string]tribler.Download{}
+	for _, download := range downloads {
+		byHash[download.Infohash] = download
+	}
+
+	if !preferences.QueueingEnabled {
+		for _, entry := range entries {
+			if entry.Queued && !renamed[entry.Hash] {
+				h.startQueued(entry.Hash)
+			}
+		}
+		return
+	}
+
+	activeDownloads, activeUploads, active := 0, 0, 0
+	// downloading torrents get the slots first, like in qBittorrent
+	for _, seeding := range []bool{false, true} {
+		for _, entry := range entries {
+			download := byHash[entry.Hash]
+			if (download.Progress >= 1) != seeding {
+				continue
+			}
+			if !entry.Queued && !isRunning(download) {
+				continue
+			}
+			// force started torrents neither take a slot nor wait for one
+			if forced[entry.Hash] || (renamed[entry.Hash] && !isRunning(download)) {
+				continue
+			}
+
+			var slot bool
+			if seeding {
+				slot = withinLimit(activeUploads, preferences.MaxActiveUploads) && withinLimit(active, preferences.MaxActiveTorrents)
+			} else {
+				slot = withinLimit(activeDownloads, preferences.MaxActiveDownloads) && withinLimit(active, preferences.MaxActiveTorrents)
+			}
+
+			if !slot {
+				if isRunning(download) {
+					h.stopQueued(entry.Hash)
+				}
+				continue
+			}
+			if seeding {
+				activeUploads++
+			} else {
+				activeDownloads++
+			}
+			active++
+			if entry.Queued {
+				h.startQueued(entry.Hash)
+			}
+		}
+	}
+}
+
+func (h *Handler) startQueued(hash string) {
+	logbuffer.Info("Queue: starting %s", hash)
+	if err := tribler.UpdateDownload(hash, "resume"); err != nil {
+		logbuffer.Warning("Error resuming %s: %+v", hash, err)
+		return
+	}
+	if err := h.DB.SetQueued([]string{hash}, false); err != nil {
+		logbuffer.Warning("Error updating queue of %s: %+v", hash, err)
+	}
+}
+
+func (h *Handler) stopQueued(hash string) {
+	logbuffer.Info("Queue: queueing %s", hash)
+	if err := tribler.UpdateDownload(hash, "stop"); err != nil {
+		logbuffer.Warning("Error stopping %s: %+v", hash, err)
+		return
+	}
+	if err := h.DB.SetQueued([]string{hash}, true); err != nil {
+		logbuffer.Warning("Error updating queue of %s: %+v", hash, err)
+	}
+}
+
+// refreshQueue applies the queue right away instead of waiting for the next monitor run
+func (h *Handler) refreshQueue() {
+	h.monitorMu.Lock()
+	defer h.monitorMu.Unlock()
+
+	downloads, err := tribler.GetDownloads()
+	if err != nil {
+		logbuffer.Warning("Error getting downloads: %+v", err)
+		return
+	}
+	h.applyQueue(downloads.Downloads)
+}
+
+// queuePriorities returns the qBittorrent priority of every torrent: its 1-based position among the
+// incomplete torrents, or 0 for completed torrents and when queueing is disabled
+func queuePriorities(entries []storage.QueueEntry, downloads []tribler.Download, queueingEnabled bool) map[string]int {
+	priorities := map[string]int{}
+	if !queueingEnabled {
+		return priorities
+	}
+	completed := map[string]bool{}
+	for _, download := range downloads {
+		completed[download.Infohash] = download.Progress >= 1
+	}
+	position := 0
+	for _, entry := range entries {
+		if completed[entry.Hash] {
+			continue
+		}
+		position++
+		priorities[entry.Hash] = position
+	}
+	return priorities
+}
+
+// moveInQueue reorders the queue. move gets the current order and which hashes were selected.
+func (h *Handler) moveInQueue(c *gin.Context, move func(order []string, selected map[string]bool) []string) {
+	preferences, err := h.loadPreferences()
+	if err != nil {
+		handleInternalError(c, "Failed to load preferences", err)
+		return
+	}
+	if !preferences.QueueingEnabled {
+		c.JSON(http.StatusConflict, gin.H{"message": "Torrent queueing must be enabled"})
+		return
+	}
+
+	hashes, err := h.resolveHashes(c.PostForm("hashes"))
+	if err != nil {
+		handleInternalError(c, "Failed to resolve hashes", err)
+		return
+	}
+	selected := map[string]bool{}
+	for _, hash := range hashes {
+		selected[hash] = true
+	}
+
+	downloads, err := tribler.GetDownloads()
+	if err != nil {
+		handleInternalError(c, "Failed to get downloads", err)
+		return
+	}
+	h.monitorMu.Lock()
+	entries, err := h.queueOrder(downloads.Downloads)
+	if err == nil {
+		err = h.DB.SaveQueueOrder(move(entryHashes(entries), selected))
+	}
+	h.monitorMu.Unlock()
+	if err != nil {
+		handleInternalError(c, "Failed to update queue", err)
+		return
+	}
+
+	h.refreshQueue()
+	c.JSON(http.StatusOK, gin.H{"message": "Torrent priority set"})
+}
+
+// SetTopPriority moves torrents to the top of the queue
+func (h *Handler) SetTopPriority() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.moveInQueue(c, func(order []string, selected map[string]bool) []string {
+			top, rest := []string{}, []string{}
+			for _, hash := range order {
+				if selected[hash] {
+					top = append(top, hash)
+				} else {
+					rest = append(rest, hash)
+				}
+			}
+			return append(top, rest...)
+		})
+	}
+}
+
+// SetBottomPriority moves torrents to the bottom of the queue
+func (h *Handler) SetBottomPriority() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.moveInQueue(c, func(order []string, selected map[string]bool) []string {
+			bottom, rest := []string{}, []string{}
+			for _, hash := range order {
+				if selected[hash] {
+					bottom = append(bottom, hash)
+				} else {
+					rest = append(rest, hash)
+				}
+			}
+			return append(rest, bottom...)
+		})
+	}
+}
+
+// IncreasePriority moves torrents one position up in the queue
+func (h *Handler) IncreasePriority() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.moveInQueue(c, func(order []string, selected map[string]bool) []string {
+			for i := 1; i < len(order); i++ {
+				if selected[order[i]] && !selected[order[i-1]] {
+					order[i], order[i-1] = order[i-1], order[i]
+				}
+			}
+			return order
+		})
+	}
+}
+
+// DecreasePriority moves torrents one position down in the queue
+func (h *Handler) DecreasePriority() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.moveInQueue(c, func(order []string, selected map[string]bool) []string {
+			for i := len(order) - 2; i >= 0; i-- {
+				if selected[order[i]] && !selected[order[i+1]] {
+					order[i], order[i+1] = order[i+1], order[i]
+				}
+			}
+			return order
+		})
+	}
+}
+
+// forcedState returns the state of a force started download, or "" when force start does not change its state
+func forcedState(download tribler.Download) string {
+	switch download.Status {
+	case "DOWNLOADING":
+		return "forcedDL"
+	case "SEEDING":
+		return "forcedUP"
+	case "METADATA":
+		return "forcedMetaDL"
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package language
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// downloadFiles returns the files of a download with the paths of files renamed through the shim
+func (h *Handler) downloadFiles(hash string) (tribler.TorrentFiles, error) {
+	torrentFiles, err := tribler.GetDownloadsFiles(hash)
+	if err != nil {
+		return tribler.TorrentFiles{}, err
+	}
+	names, err := h.DB.GetTorrentFileNames(hash)
+	if err != nil {
+		return tribler.TorrentFiles{}, err
+	}
+	for i, file := range torrentFiles.Files {
+		if name, ok := names[file.Index]; ok {
+			torrentFiles.Files[i].Name = name
+		}
+	}
+	return torrentFiles, nil
+}
+
+// cleanRelativePath validates a file or folder path relative to the save path of a torrent
+func cleanRelativePath(p string) (string, bool) {
+	p = strings.Trim(strings.ReplaceAll(p, "\\", "/"), "/")
+	if p == "" {
+		return "", false
+	}
+	cleaned := path.Clean(p)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+	return cleaned, true
+}
+
+// moveOnDisk renames a file or folder inside the save path of a download
+func moveOnDisk(destination, oldPath, newPath string) error {
+	oldFull := filepath.Join(destination, filepath.FromSlash(oldPath))
+	newFull := filepath.Join(destination, filepath.FromSlash(newPath))
+	if _, err := os.Stat(newFull); err == nil {
+		return os.ErrExist
+	}
+	if err := os.MkdirAll(filepath.Dir(newFull), 0755); err != nil {
+		return err
+	}
+	return os.Rename(oldFull, newFull)
+}
+
+// errRenamedFiles is the answer to starting or rechecking torrents whose files were renamed by the shim
+const errRenamedFiles = "Torrents with renamed files cannot be started or rechecked"
+
+// withoutRenamedFiles splits off the torrents with renamed files. Tribler still expects their files at the
+// original paths, so starting or rechecking them would make it report errors or download the files again.
+func (h *Handler) withoutRenamedFiles(hashes []string) ([]string, []string, error) {
+	renamed, err := h.DB.GetRenamedTorrents()
+	if err != nil {
+		return nil, nil, err
+	}
+	allowed, refused := []string{}, []string{}
+	for _, hash := range hashes {
+		if renamed[hash] {
+			refused = append(refused, hash)
+		} else {
+			allowed = append(allowed, hash)
+		}
+	}
+	return allowed, refused, nil
+}
+
+// refuseRenamedFiles answers with 409 once the other torrents of a request have been handled
+func refuseRenamedFiles(c *gin.Context, refused []string) {
+	logbuffer.Warning("Not starting or rechecking %s: %s", strings.Join(refused, ", "), errRenamedFiles)
+	c.JSON(http.StatusConflict, gin.H{"message": errRenamedFiles})
+}
+
+// renamePaths renames the files matching oldPath on disk and stores their new paths. With folder set, every file
+// inside the oldPath folder is moved to newPath.
+func (h *Handler) renamePaths(c *gin.Context, folder bool) {
+	hash := strings.ToLower(c.PostForm("hash"))
+	if c.PostForm("newPath") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Missing newPath"})
+		return
+	}
+	oldPath, okOld := cleanRelativePath(c.PostForm("oldPath"))
+	newPath, okNew := cleanRelativePath(c.PostForm("newPath"))
+	if !okOld || !okNew {
+		c.JSON(http.StatusConflict, gin.H{"message": "Invalid path"})
+		return
+	}
+
+	download, err := tribler.GetDownload(hash)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Torrent not found"})
+		return
+	}
+	// Tribler keeps writing and seeding the files under their original paths
+	if download.Progress < 1 || isRunning(download) {
+		c.JSON(http.StatusConflict, gin.H{"message": "Torrent must be complete and paused to rename its files"})
+		return
+	}
+	torrentFiles, err := h.downloadFiles(hash)
+	if err != nil {
+		handleInternalError(c, "Failed to get files", err)
+		return
+	}
+
+	renamed := map[int]string{}
+	for _, file := range torrentFiles.Files {
+		if file.Name == newPath || (folder && strings.HasPrefix(file.Name, newPath+"/")) {
+			c.JSON(http.StatusConflict, gin.H{"message": "newPath is already in use"})
+			return
+		}
+		switch {
+		case !folder && file.Name == oldPath:
+			renamed[file.Index] = newPath
+		case folder && strings.HasPrefix(file.Name, oldPath+"/"):
+			renamed[file.Index] = newPath + strings.TrimPrefix(file.Name, oldPath)
+		}
+	}
+	if len(renamed) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"message": "oldPath not found"})
+		return
+	}
+
+	err = moveOnDisk(download.Destination, oldPath, newPath)
+	if errors.Is(err, os.ErrExist) {
+		c.JSON(http.StatusConflict, gin.H{"message": "newPath is already in use"})
+		return
+	}
+	if err != nil {
+		logbuffer.Warning("Error renaming %s to %s in %s: %+v", oldPath, newPath, download.Destination, err)
+		c.JSON(http.StatusConflict, gin.H{"message": "Failed to rename " + oldPath})
+		return
+	}
+	err = h.DB.SetTorrentFileNames(hash, renamed)
+	if err != nil {
+		handleInternalError(c, "Failed to store renamed files", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Renamed"})
+}
+
+// RenameTorrent sets the name a torrent is displayed with
+func (h *Handler) RenameTorrent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash := strings.ToLower(c.PostForm("hash"))
+		name := strings.TrimSpace(c.PostForm("name"))
+		if name == "" {
+			c.JSON(http.StatusConflict, gin.H{"message": "Invalid torrent name"})
+			return
+		}
+		if _, err := tribler.GetDownload(hash); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Torrent not found"})
+			return
+		}
+		err := h.DB.SetTorrentName(hash, name)
+		if err != nil {
+			handleInternalError(c, "Failed to rename torrent", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Torrent renamed"})
+	}
+}
+
+// RenameFile renames a file of a torrent on disk
+func (h *Handler) RenameFile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.renamePaths(c, false)
+	}
+}
+
+// RenameFolder renames a folder of a torrent on disk
+func (h *Handler) RenameFolder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.renamePaths(c, true)
+	}
+}
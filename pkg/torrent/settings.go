@@ -0,0 +1,129 @@
+package language
+
+import (
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/tribler"
+)
+
+// qBittorrent bittorrent_protocol values
+const (
+	protocolTCPAndUTP = 0
+	protocolTCP       = 1
+	protocolUTP       = 2
+)
+
+// triblerPreference maps a preference to Tribler's libtorrent settings
+type triblerPreference struct {
+	read  func(settings tribler.LibtorrentSettings, preferences *AppPreferences)
+	write func(preferences AppPreferences, libtorrent map[string]interface{})
+}
+
+// triblerPreferences are the preferences that mirror Tribler's settings rather than being stored by the shim
+var triblerPreferences = map[string]triblerPreference{
+	"dl_limit": {
+		read: func(settings tribler.LibtorrentSettings, preferences *AppPreferences) {
+			preferences.DlLimit = speedLimit(settings.MaxDownloadRate)
+		},
+		write: func(preferences AppPreferences, libtorrent map[string]interface{}) {
+			libtorrent["max_download_rate"] = bytesToKib(preferences.DlLimit)
+		},
+	},
+	"up_limit": {
+		read: func(settings tribler.LibtorrentSettings, preferences *AppPreferences) {
+			preferences.UpLimit = speedLimit(settings.MaxUploadRate)
+		},
+		write: func(preferences AppPreferences, libtorrent map[string]interface{}) {
+			libtorrent["max_upload_rate"] = bytesToKib(preferences.UpLimit)
+		},
+	},
+	"listen_port": {
+		read: func(settings tribler.LibtorrentSettings, preferences *AppPreferences) {
+			preferences.ListenPort = settings.Port
+		},
+		write: func(preferences AppPreferences, libtorrent map[string]interface{}) {
+			libtorrent["port"] = preferences.ListenPort
+		},
+	},
+	"max_connec": {
+		read: func(settings tribler.LibtorrentSettings, preferences *AppPreferences) {
+			preferences.MaxConnec = settings.MaxConnectionsDownload
+		},
+		write: func(preferences AppPreferences, libtorrent map[string]interface{}) {
+			libtorrent["max_connections_download"] = preferences.MaxConnec
+		},
+	},
+	"bittorrent_protocol": {
+		read: func(settings tribler.LibtorrentSettings, preferences *AppPreferences) {
+			preferences.BittorrentProtocol = protocolTCP
+			if settings.Utp {
+				preferences.BittorrentProtocol = protocolTCPAndUTP
+			}
+		},
+		write: func(preferences AppPreferences, libtorrent map[string]interface{}) {
+			// Tribler cannot turn TCP off, so uTP only is treated like TCP and uTP
+			libtorrent["utp"] = preferences.BittorrentProtocol != protocolTCP
+		},
+	},
+	"dht": {
+		read: func(settings tribler.LibtorrentSettings, preferences *AppPreferences) {
+			preferences.DhtEnabled = settings.Dht
+		},
+		write: func(preferences AppPreferences, libtorrent map[string]interface{}) {
+			libtorrent["dht"] = preferences.DhtEnabled
+		},
+	},
+	"upnp": {
+		read: func(settings tribler.LibtorrentSettings, preferences *AppPreferences) {
+			preferences.Upnp = settings.Upnp
+		},
+		write: func(preferences AppPreferences, libtorrent map[string]interface{}) {
+			libtorrent["upnp"] = preferences.Upnp
+			libtorrent["natpmp"] = preferences.Upnp
+		},
+	},
+	"lsd": {
+		read: func(settings tribler.LibtorrentSettings, preferences *AppPreferences) {
+			preferences.Lsd = settings.Lsd
+		},
+		write: func(preferences AppPreferences, libtorrent map[string]interface{}) {
+			libtorrent["lsd"] = preferences.Lsd
+		},
+	},
+	// tribler_anon_hops is not a qBittorrent preference. It is Tribler's default number of hops for downloads
+	// added from Tribler itself, the shim adds its downloads with TRIBLER_ANON_HOPS.
+	"tribler_anon_hops": {
+		read: func(settings tribler.LibtorrentSettings, preferences *AppPreferences) {
+			preferences.TriblerAnonHops = settings.DownloadDefaults.NumberHops
+		},
+		write: func(preferences AppPreferences, libtorrent map[string]interface{}) {
+			downloadDefaults(libtorrent)["number_hops"] = preferences.TriblerAnonHops
+		},
+	},
+}
+
+// downloadDefaults returns the download_defaults section of a libtorrent settings update
+func downloadDefaults(libtorrent map[string]interface{}) map[string]interface{} {
+	defaults, ok := libtorrent["download_defaults"].(map[string]interface{})
+	if !ok {
+		defaults = map[string]interface{}{}
+		libtorrent["download_defaults"] = defaults
+	}
+	return defaults
+}
+
+// withTriblerPreferences fills in the preferences that are read from Tribler's settings. Without
+// TRIBLER_DOWNLOAD_DIR, torrents are saved where Tribler saves them by default.
+func withTriblerPreferences(preferences AppPreferences) AppPreferences {
+	settings, err := tribler.GetSettings()
+	if err != nil {
+		logbuffer.Warning("Error getting Tribler settings: %+v", err)
+		return preferences
+	}
+	for _, mapping := range triblerPreferences {
+		mapping.read(settings.Libtorrent, &preferences)
+	}
+	if preferences.SavePath == "" {
+		preferences.SavePath = settings.Libtorrent.DownloadDefaults.Saveas
+	}
+	return preferences
+}
@@ -0,0 +1,171 @@
+package language
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/storage"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Special share limit values, as used by qBittorrent
+const (
+	useGlobalShareLimit = -2
+	noShareLimit        = -1
+)
+
+// Values of the max_ratio_act preference
+const (
+	shareLimitActionPause           = 0
+	shareLimitActionRemove          = 1
+	shareLimitActionSuperSeeding    = 2
+	shareLimitActionRemoveWithFiles = 3
+)
+
+func defaultShareLimits(hash string) storage.ShareLimits {
+	return storage.ShareLimits{
+		Hash:                     hash,
+		RatioLimit:               useGlobalShareLimit,
+		SeedingTimeLimit:         useGlobalShareLimit,
+		InactiveSeedingTimeLimit: useGlobalShareLimit,
+	}
+}
+
+// effectiveShareLimits replaces the "use global limit" values of a torrent with the global preferences
+func effectiveShareLimits(limits storage.ShareLimits, preferences AppPreferences) storage.ShareLimits {
+	if limits.RatioLimit == useGlobalShareLimit {
+		limits.RatioLimit = noShareLimit
+		if preferences.MaxRatioEnabled {
+			limits.RatioLimit = preferences.MaxRatio
+		}
+	}
+	if limits.SeedingTimeLimit == useGlobalShareLimit {
+		limits.SeedingTimeLimit = noShareLimit
+		if preferences.MaxSeedingTimeEnabled {
+			limits.SeedingTimeLimit = preferences.MaxSeedingTime
+		}
+	}
+	if limits.InactiveSeedingTimeLimit == useGlobalShareLimit {
+		limits.InactiveSeedingTimeLimit = noShareLimit
+		if preferences.MaxInactiveSeedingTimeEnabled {
+			limits.InactiveSeedingTimeLimit = preferences.MaxInactiveSeedingTime
+		}
+	}
+	return limits
+}
+
+// shareLimitReached returns why a torrent reached its effective share limits, or "" if it did not
+func shareLimitReached(download tribler.Download, limits storage.ShareLimits, stats storage.TorrentStats, now time.Time) string {
+	if limits.RatioLimit >= 0 && download.AllTimeRatio >= limits.RatioLimit {
+		return "ratio limit"
+	}
+	if limits.SeedingTimeLimit >= 0 && stats.SeedingTime >= int64(limits.SeedingTimeLimit)*60 {
+		return "seeding time limit"
+	}
+	if limits.InactiveSeedingTimeLimit >= 0 && now.Unix()-stats.LastActivity >= int64(limits.InactiveSeedingTimeLimit)*60 {
+		return "inactive seeding time limit"
+	}
+	return ""
+}
+
+// enforceShareLimits pauses or removes seeding torrents that reached their share limits, depending on
+// max_ratio_act. It reports whether any torrent was acted upon.
+func (h *Handler) enforceShareLimits(downloads []tribler.Download, stats map[string]storage.TorrentStats) bool {
+	preferences, err := h.loadPreferences()
+	if err != nil {
+		logbuffer.Warning("Error loading preferences: %+v", err)
+		return false
+	}
+	shareLimits, err := h.DB.GetShareLimits()
+	if err != nil {
+		logbuffer.Warning("Error getting share limits: %+v", err)
+		return false
+	}
+	forced, err := h.DB.GetForceStarted()
+	if err != nil {
+		logbuffer.Warning("Error getting force started torrents: %+v", err)
+		return false
+	}
+
+	acted := false
+	now := time.Now()
+	for _, download := range downloads {
+		if download.Progress < 1 || !isRunning(download) || forced[download.Infohash] {
+			continue
+		}
+		limits, ok := shareLimits[download.Infohash]
+		if !ok {
+			limits = defaultShareLimits(download.Infohash)
+		}
+		reason := shareLimitReached(download, effectiveShareLimits(limits, preferences), stats[download.Infohash], now)
+		if reason == "" {
+			continue
+		}
+		acted = true
+
+		switch preferences.MaxRatioAction {
+		case shareLimitActionRemove, shareLimitActionRemoveWithFiles:
+			removeData := preferences.MaxRatioAction == shareLimitActionRemoveWithFiles
+			logbuffer.Info("Removing %s (%s), %s reached", download.Infohash, download.Name, reason)
+			if err := tribler.DeleteDownload(download.Infohash, removeData); err != nil {
+				logbuffer.Warning("Error removing %s: %+v", download.Infohash, err)
+				continue
+			}
+			if err := h.DB.ForgetTorrent(download.Infohash); err != nil {
+				logbuffer.Warning("Error forgetting %s: %+v", download.Infohash, err)
+			}
+		default:
+			if preferences.MaxRatioAction == shareLimitActionSuperSeeding {
+				logbuffer.Warning("Super seeding is not supported by Tribler, pausing %s instead", download.Infohash)
+			}
+			logbuffer.Info("Pausing %s (%s), %s reached", download.Infohash, download.Name, reason)
+			if err := tribler.UpdateDownload(download.Infohash, "stop"); err != nil {
+				logbuffer.Warning("Error pausing %s: %+v", download.Infohash, err)
+			}
+		}
+	}
+	return acted
+}
+
+// SetShareLimits sets the ratio, seeding time and inactive seeding time limits of torrents
+func (h *Handler) SetShareLimits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ratioLimit, err := strconv.ParseFloat(c.PostForm("ratioLimit"), 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid ratioLimit"})
+			return
+		}
+		seedingTimeLimit, err := strconv.Atoi(c.PostForm("seedingTimeLimit"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid seedingTimeLimit"})
+			return
+		}
+		inactiveSeedingTimeLimit := useGlobalShareLimit
+		if value := c.PostForm("inactiveSeedingTimeLimit"); value != "" {
+			inactiveSeedingTimeLimit, err = strconv.Atoi(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid inactiveSeedingTimeLimit"})
+				return
+			}
+		}
+
+		hashes, err := h.resolveHashes(c.PostForm("hashes"))
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
+		}
+		err = h.DB.SetShareLimits(hashes, storage.ShareLimits{
+			RatioLimit:               ratioLimit,
+			SeedingTimeLimit:         seedingTimeLimit,
+			InactiveSeedingTimeLimit: inactiveSeedingTimeLimit,
+		})
+		if err != nil {
+			handleInternalError(c, "Failed to set share limits", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Torrent share limits set"})
+	}
+}
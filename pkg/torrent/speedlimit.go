@@ -0,0 +1,82 @@
+package language
+
+import (
+	"net/http"
+	"strconv"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// speedLimit converts a Tribler max speed in KiB/s, where 0 means unlimited, to qBittorrent's bytes per
+// second with -1 for unlimited
+func speedLimit(maxSpeed int) int {
+	if maxSpeed <= 0 {
+		return -1
+	}
+	return kibToBytes(maxSpeed)
+}
+
+// torrentSpeedLimits returns the speed limit of every requested torrent, keyed by hash
+func (h *Handler) torrentSpeedLimits(c *gin.Context, maxSpeed func(tribler.Download) int) {
+	hashes, err := h.resolveHashes(c.PostForm("hashes"))
+	if err != nil {
+		handleInternalError(c, "Failed to resolve hashes", err)
+		return
+	}
+	limits := map[string]int{}
+	for _, hash := range hashes {
+		download, err := tribler.GetDownload(hash)
+		if err != nil {
+			logbuffer.Warning("Error getting download %s: %+v", hash, err)
+			continue
+		}
+		limits[hash] = speedLimit(maxSpeed(download))
+	}
+	c.JSON(http.StatusOK, limits)
+}
+
+// rejectTorrentSpeedLimits answers a request to set per torrent speed limits. Tribler's REST API can only
+// report the maximum speeds of a download, it has no way to change them.
+func rejectTorrentSpeedLimits(c *gin.Context) {
+	c.JSON(http.StatusConflict, gin.H{"message": "Per torrent speed limits are not supported by Tribler"})
+}
+
+// GetTorrentDownloadLimit retrieves the download limit of torrents
+func (h *Handler) GetTorrentDownloadLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.torrentSpeedLimits(c, func(download tribler.Download) int { return download.MaxDownloadSpeed })
+	}
+}
+
+// GetTorrentUploadLimit retrieves the upload limit of torrents
+func (h *Handler) GetTorrentUploadLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.torrentSpeedLimits(c, func(download tribler.Download) int { return download.MaxUploadSpeed })
+	}
+}
+
+// SetTorrentDownloadLimit would set the download limit of torrents, which Tribler does not support
+func (h *Handler) SetTorrentDownloadLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rejectTorrentSpeedLimits(c)
+	}
+}
+
+// SetTorrentUploadLimit would set the upload limit of torrents, which Tribler does not support
+func (h *Handler) SetTorrentUploadLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rejectTorrentSpeedLimits(c)
+	}
+}
+
+// logIgnoredSpeedLimits notes the dlLimit and upLimit fields of torrents/add, which cannot be applied to
+// Tribler downloads
+func logIgnoredSpeedLimits(c *gin.Context, infohash string) {
+	for _, field := range []string{"dlLimit", "upLimit"} {
+		if limit, err := strconv.Atoi(c.PostForm(field)); err == nil && limit > 0 {
+			logbuffer.Warning("Ignoring %s of %s: per torrent speed limits are not supported by Tribler", field, infohash)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package language
+
+import (
+	"strings"
+	"tribler-arr-shim/pkg/tribler"
+)
+
+// triblerState is how a Tribler download status maps to qBittorrent states
+type triblerState struct {
+	// incomplete and complete are the states before and after all data has been downloaded
+	incomplete string
+	complete   string
+	// transferring statuses are reported as stalled while no data is moving
+	transferring bool
+}
+
+var triblerStates = map[string]triblerState{
+	"ALLOCATING_DISKSPACE":  {incomplete: "allocating", complete: "allocating"},
+	"WAITING_FOR_HASHCHECK": {incomplete: "checkingDL", complete: "checkingUP"},
+	"HASHCHECKING":          {incomplete: "checkingDL", complete: "checkingUP"},
+	"METADATA":              {incomplete: "metaDL", complete: "metaDL"},
+	// Tribler is building the anonymity circuits the download needs before it can connect to peers
+	"CIRCUITS":         {incomplete: "stalledDL", complete: "stalledUP"},
+	"EXIT_NODES":       {incomplete: "stalledDL", complete: "stalledUP"},
+	"DOWNLOADING":      {transferring: true},
+	"SEEDING":          {transferring: true},
+	"STOPPED":          {incomplete: "pausedDL", complete: "pausedUP"},
+	"STOPPED_ON_ERROR": {incomplete: "error", complete: "error"},
+	"LOADING":          {incomplete: "checkingResumeData", complete: "checkingResumeData"},
+}
+
+// torrentState returns the qBittorrent state of a Tribler download
+func torrentState(download tribler.Download) string {
+	mapping, ok := triblerStates[download.Status]
+	if !ok {
+		return "unknown"
+	}
+	complete := download.Progress >= 1
+
+	if mapping.transferring {
+		switch {
+		case !complete && download.SpeedDown > 0:
+			return "downloading"
+		case !complete:
+			return "stalledDL"
+		case download.SpeedUp > 0:
+			return "uploading"
+		default:
+			return "stalledUP"
+		}
+	}
+
+	if download.Status == "STOPPED_ON_ERROR" && isMissingFilesError(download.Error) {
+		return "missingFiles"
+	}
+	if complete {
+		return mapping.complete
+	}
+	return mapping.incomplete
+}
+
+// isMissingFilesError tells whether a Tribler error is about data that is gone from disk
+func isMissingFilesError(err string) bool {
+	lower := strings.ToLower(err)
+	return strings.Contains(lower, "no such file") || strings.Contains(lower, "cannot find the")
+}
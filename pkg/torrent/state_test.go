@@ -0,0 +1,80 @@
+package language
+
+import (
+	"testing"
+	"tribler-arr-shim/pkg/tribler"
+)
+
+func TestTorrentState(t *testing.T) {
+	tests := []struct {
+		name     string
+		download tribler.Download
+		want     string
+	}{
+		{"allocating", tribler.Download{Status: "ALLOCATING_DISKSPACE", Progress: 0.5}, "allocating"},
+		{"allocating complete", tribler.Download{Status: "ALLOCATING_DISKSPACE", Progress: 1}, "allocating"},
+		{"waiting for hashcheck", tribler.Download{Status: "WAITING_FOR_HASHCHECK", Progress: 0.5}, "checkingDL"},
+		{"waiting for hashcheck complete", tribler.Download{Status: "WAITING_FOR_HASHCHECK", Progress: 1}, "checkingUP"},
+		{"hashchecking", tribler.Download{Status: "HASHCHECKING", Progress: 0.5}, "checkingDL"},
+		{"hashchecking complete", tribler.Download{Status: "HASHCHECKING", Progress: 1}, "checkingUP"},
+		{"metadata", tribler.Download{Status: "METADATA"}, "metaDL"},
+		{"metadata complete", tribler.Download{Status: "METADATA", Progress: 1}, "metaDL"},
+		{"circuits", tribler.Download{Status: "CIRCUITS", Progress: 0.5}, "stalledDL"},
+		{"circuits complete", tribler.Download{Status: "CIRCUITS", Progress: 1}, "stalledUP"},
+		{"exit nodes", tribler.Download{Status: "EXIT_NODES", Progress: 0.5}, "stalledDL"},
+		{"exit nodes complete", tribler.Download{Status: "EXIT_NODES", Progress: 1}, "stalledUP"},
+		{"downloading", tribler.Download{Status: "DOWNLOADING", Progress: 0.5, SpeedDown: 1024}, "downloading"},
+		{"downloading stalled", tribler.Download{Status: "DOWNLOADING", Progress: 0.5}, "stalledDL"},
+		{"downloading complete uploading", tribler.Download{Status: "DOWNLOADING", Progress: 1, SpeedUp: 1024}, "uploading"},
+		{"downloading complete stalled", tribler.Download{Status: "DOWNLOADING", Progress: 1}, "stalledUP"},
+		{"seeding", tribler.Download{Status: "SEEDING", Progress: 1, SpeedUp: 1024}, "uploading"},
+		{"seeding stalled", tribler.Download{Status: "SEEDING", Progress: 1}, "stalledUP"},
+		{"seeding incomplete", tribler.Download{Status: "SEEDING", Progress: 0.5, SpeedDown: 1024}, "downloading"},
+		{"seeding incomplete stalled", tribler.Download{Status: "SEEDING", Progress: 0.5}, "stalledDL"},
+		{"stopped", tribler.Download{Status: "STOPPED", Progress: 0.5}, "pausedDL"},
+		{"stopped complete", tribler.Download{Status: "STOPPED", Progress: 1}, "pausedUP"},
+		{"stopped on error", tribler.Download{Status: "STOPPED_ON_ERROR", Progress: 0.5, Error: "disk full"}, "error"},
+		{"stopped on error complete", tribler.Download{Status: "STOPPED_ON_ERROR", Progress: 1, Error: "disk full"}, "error"},
+		{"missing files", tribler.Download{Status: "STOPPED_ON_ERROR", Progress: 1, Error: "[Errno 2] No such file or directory"}, "missingFiles"},
+		{"missing files incomplete", tribler.Download{Status: "STOPPED_ON_ERROR", Progress: 0.5, Error: "The system cannot find the file specified"}, "missingFiles"},
+		{"loading", tribler.Download{Status: "LOADING", Progress: 0.5}, "checkingResumeData"},
+		{"loading complete", tribler.Download{Status: "LOADING", Progress: 1}, "checkingResumeData"},
+		{"unknown", tribler.Download{Status: "SOMETHING_NEW", Progress: 0.5}, "unknown"},
+		{"empty", tribler.Download{}, "unknown"},
+	}
+
+	covered := map[string]bool{}
+	for _, tt := range tests {
+		covered[tt.download.Status] = true
+		t.Run(tt.name, func(t *testing.T) {
+			if got := torrentState(tt.download); got != tt.want {
+				t.Errorf("torrentState() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	for status := range triblerStates {
+		if !covered[status] {
+			t.Errorf("no test case for Tribler status %s", status)
+		}
+	}
+}
+
+func TestIsMissingFilesError(t *testing.T) {
+	tests := []struct {
+		err  string
+		want bool
+	}{
+		{"[Errno 2] No such file or directory: '/downloads/a'", true},
+		{"NO SUCH FILE", true},
+		{"The system cannot find the path specified", true},
+		{"The system cannot find the file specified", true},
+		{"[Errno 28] No space left on device", false},
+		{"Permission denied", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isMissingFilesError(tt.err); got != tt.want {
+			t.Errorf("isMissingFilesError(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package language
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// syncSnapshot is the data a client received with its last maindata or torrentPeers response
+type syncSnapshot struct {
+	rid         int
+	updated     time.Time
+	torrents    map[string]map[string]interface{}
+	categories  map[string]map[string]interface{}
+	tags        []string
+	serverState map[string]interface{}
+	peers       map[string]map[string]interface{}
+}
+
+// syncState keeps the last snapshot of every client so that maindata can answer with a diff
+type syncState struct {
+	mu        sync.Mutex
+	lastRid   int
+	snapshots map[string]*syncSnapshot
+}
+
+func newSyncState() *syncState {
+	return &syncState{snapshots: map[string]*syncSnapshot{}}
+}
+
+// swap stores the snapshot for the client and returns the one it replaces. Snapshots not updated
+// within timeout are dropped.
+func (s *syncState) swap(client string, current *syncSnapshot, timeout time.Duration) *syncSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// clients that stopped polling have most likely lost their session as well
+	for key, snapshot := range s.snapshots {
+		if time.Since(snapshot.updated) > timeout {
+			delete(s.snapshots, key)
+		}
+	}
+
+	s.lastRid++
+	current.rid = s.lastRid
+	current.updated = time.Now()
+	previous := s.snapshots[client]
+	s.snapshots[client] = current
+	return previous
+}
+
+func (s *syncState) forget(client string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.snapshots, client)
+}
+
+// GetMainData implements /sync/maindata. The first request of a client, or a request with a rid the
+// client did not get from us last, receives a full update. Later requests only receive what changed.
+func (h *Handler) GetMainData() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rid, _ := strconv.Atoi(c.Query("rid"))
+
+		downloads, err := tribler.GetDownloads()
+		if err != nil {
+			handleInternalError(c, "Failed to get downloads", err)
+			return
+		}
+		torrents, err := h.torrentsFromDownloads(downloads.Downloads)
+		if err != nil {
+			handleInternalError(c, "Failed to get torrents", err)
+			return
+		}
+		categories, err := h.categoriesMap()
+		if err != nil {
+			handleInternalError(c, "Failed to get categories", err)
+			return
+		}
+		tags, err := h.DB.GetTags()
+		if err != nil {
+			handleInternalError(c, "Failed to get tags", err)
+			return
+		}
+		preferences, err := h.loadPreferences()
+		if err != nil {
+			handleInternalError(c, "Failed to load preferences", err)
+			return
+		}
+		state := ServerState{
+			TransferInfo:    h.transferInfo(downloads.Downloads),
+			Queueing:        preferences.QueueingEnabled,
+			RefreshInterval: 1500,
+		}
+
+		current := &syncSnapshot{
+			torrents:    map[string]map[string]interface{}{},
+			categories:  map[string]map[string]interface{}{},
+			tags:        tags,
+			serverState: toMap(state),
+		}
+		for _, torrent := range torrents {
+			current.torrents[torrent.Hash] = toMap(torrent)
+		}
+		for name, category := range categories {
+			current.categories[name] = toMap(category)
+		}
+
+		previous := h.sync.swap(c.GetString(sidContextKey), current, sessionTimeout(preferences))
+
+		if rid == 0 || previous == nil || previous.rid != rid {
+			c.JSON(http.StatusOK, gin.H{
+				"rid":          current.rid,
+				"full_update":  true,
+				"torrents":     current.torrents,
+				"categories":   current.categories,
+				"tags":         current.tags,
+				"server_state": current.serverState,
+			})
+			return
+		}
+
+		response := gin.H{"rid": current.rid}
+		changed, removed := diffObjects(previous.torrents, current.torrents)
+		if len(changed) > 0 {
+			response["torrents"] = changed
+		}
+		if len(removed) > 0 {
+			response["torrents_removed"] = removed
+		}
+		changed, removed = diffObjects(previous.categories, current.categories)
+		if len(changed) > 0 {
+			response["categories"] = changed
+		}
+		if len(removed) > 0 {
+			response["categories_removed"] = removed
+		}
+		added, removed := diffStrings(previous.tags, current.tags)
+		if len(added) > 0 {
+			response["tags"] = added
+		}
+		if len(removed) > 0 {
+			response["tags_removed"] = removed
+		}
+		if state := diffFields(previous.serverState, current.serverState); len(state) > 0 {
+			response["server_state"] = state
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// ServerState is the server_state object of maindata
+type ServerState struct {
+	TransferInfo
+	Queueing          bool `json:"queueing"`
+	UseAltSpeedLimits bool `json:"use_alt_speed_limits"`
+	RefreshInterval   int  `json:"refresh_interval"`
+}
+
+// toMap converts a struct to its JSON object representation so that fields can be compared one by one
+func toMap(v interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	b, err := json.Marshal(v)
+	if err != nil {
+		logbuffer.Warning("Error marshalling %T: %+v", v, err)
+		return m
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		logbuffer.Warning("Error unmarshalling %T: %+v", v, err)
+	}
+	return m
+}
+
+// diffFields returns the fields of current that are new or differ from previous
+func diffFields(previous, current map[string]interface{}) map[string]interface{} {
+	changed := map[string]interface{}{}
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || !reflect.DeepEqual(old, value) {
+			changed[key] = value
+		}
+	}
+	return changed
+}
+
+// diffObjects returns the changed fields of every object keyed by id and the ids that disappeared
+func diffObjects(previous, current map[string]map[string]interface{}) (map[string]map[string]interface{}, []string) {
+	changed := map[string]map[string]interface{}{}
+	for id, object := range current {
+		old, ok := previous[id]
+		if !ok {
+			changed[id] = object
+			continue
+		}
+		if fields := diffFields(old, object); len(fields) > 0 {
+			changed[id] = fields
+		}
+	}
+
+	removed := []string{}
+	for id := range previous {
+		if _, ok := current[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	return changed, removed
+}
+
+// diffStrings returns the values that were added to and removed from a list
+func diffStrings(previous, current []string) ([]string, []string) {
+	before := map[string]bool{}
+	for _, v := range previous {
+		before[v] = true
+	}
+	after := map[string]bool{}
+	for _, v := range current {
+		after[v] = true
+	}
+
+	added := []string{}
+	for _, v := range current {
+		if !before[v] {
+			added = append(added, v)
+		}
+	}
+	removed := []string{}
+	for _, v := range previous {
+		if !after[v] {
+			removed = append(removed, v)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,138 @@
+package language
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseTags splits the comma separated tags parameter
+func parseTags(tags string) []string {
+	parsed := []string{}
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			parsed = append(parsed, tag)
+		}
+	}
+	return parsed
+}
+
+// syncTriblerTags mirrors the stored tags of torrents into Tribler's tag store when TRIBLER_SYNC_TAGS is enabled
+func (h *Handler) syncTriblerTags(hashes []string) {
+	if os.Getenv("TRIBLER_SYNC_TAGS") != "true" {
+		return
+	}
+	torrentTags, err := h.DB.GetTorrentTags()
+	if err != nil {
+		logbuffer.Warning("Error getting torrent tags: %+v", err)
+		return
+	}
+	for _, hash := range hashes {
+		tags := torrentTags[hash]
+		if tags == nil {
+			tags = []string{}
+		}
+		if err := tribler.SetDownloadTags(hash, tags); err != nil {
+			logbuffer.Warning("Error syncing tags of %s to Tribler: %+v", hash, err)
+		}
+	}
+}
+
+// GetTags retrieves all tags
+func (h *Handler) GetTags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tags, err := h.DB.GetTags()
+		if err != nil {
+			handleInternalError(c, "Failed to get tags", err)
+			return
+		}
+		c.JSON(http.StatusOK, tags)
+	}
+}
+
+// CreateTags creates new tags
+func (h *Handler) CreateTags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := h.DB.CreateTags(parseTags(c.PostForm("tags")))
+		if err != nil {
+			handleInternalError(c, "Failed to create tags", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Tags created"})
+	}
+}
+
+// DeleteTags deletes tags and removes them from all torrents
+func (h *Handler) DeleteTags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		torrentTags, err := h.DB.GetTorrentTags()
+		if err != nil {
+			handleInternalError(c, "Failed to get torrent tags", err)
+			return
+		}
+
+		tags := parseTags(c.PostForm("tags"))
+		err = h.DB.DeleteTags(tags)
+		if err != nil {
+			handleInternalError(c, "Failed to delete tags", err)
+			return
+		}
+
+		deleted := map[string]bool{}
+		for _, tag := range tags {
+			deleted[tag] = true
+		}
+		affected := []string{}
+		for hash, torrentTag := range torrentTags {
+			for _, tag := range torrentTag {
+				if deleted[tag] {
+					affected = append(affected, hash)
+					break
+				}
+			}
+		}
+		h.syncTriblerTags(affected)
+		c.JSON(http.StatusOK, gin.H{"message": "Tags deleted"})
+	}
+}
+
+// AddTags adds tags to torrents
+func (h *Handler) AddTags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hashes, err := h.resolveHashes(c.PostForm("hashes"))
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
+		}
+		err = h.DB.AddTorrentTags(hashes, parseTags(c.PostForm("tags")))
+		if err != nil {
+			handleInternalError(c, "Failed to add tags", err)
+			return
+		}
+		h.syncTriblerTags(hashes)
+		c.JSON(http.StatusOK, gin.H{"message": "Tags added"})
+	}
+}
+
+// RemoveTags removes tags from torrents, or all of their tags when no tags are given
+func (h *Handler) RemoveTags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hashes, err := h.resolveHashes(c.PostForm("hashes"))
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
+		}
+		err = h.DB.RemoveTorrentTags(hashes, parseTags(c.PostForm("tags")))
+		if err != nil {
+			handleInternalError(c, "Failed to remove tags", err)
+			return
+		}
+		h.syncTriblerTags(hashes)
+		c.JSON(http.StatusOK, gin.H{"message": "Tags removed"})
+	}
+}
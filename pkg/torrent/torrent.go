@@ -1,15 +1,22 @@
 package language
 
 import (
+	"errors"
+	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"path"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"tribler-arr-shim/pkg/logbuffer"
 	"tribler-arr-shim/pkg/storage"
 	"tribler-arr-shim/pkg/tribler"
 
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
@@ -30,13 +37,32 @@ type Torrent struct {
 	NumSeeds      int     `json:"num_seeds"`
 	Priority      int     `json:"priority"`
 	Upspeed       int     `json:"upspeed"`
-	Ratio         int     `json:"ratio"`
-	Eta           float64 `json:"eta"`
+	Ratio         float64 `json:"ratio"`
+	Eta           int     `json:"eta"`
 	Size          int     `json:"size"`
 	FLPiecePrio   bool    `json:"f_l_piece_prio"`
 	SeqDL         bool    `json:"seq_dl"`
 	SuperSeeding  bool    `json:"super_seeding"`
 	ForceStart    bool    `json:"force_start"`
+	DlLimit       int     `json:"dl_limit"`
+	UpLimit       int     `json:"up_limit"`
+	AddedOn       int     `json:"added_on"`
+	CompletionOn  int     `json:"completion_on"`
+	AmountLeft    int     `json:"amount_left"`
+	Downloaded    int     `json:"downloaded"`
+	Uploaded      int     `json:"uploaded"`
+	SavePath      string  `json:"save_path"`
+	LastActivity  int     `json:"last_activity"`
+	SeedingTime   int     `json:"seeding_time"`
+	Availability  float64 `json:"availability"`
+	Tracker       string  `json:"tracker"`
+
+	RatioLimit               float64 `json:"ratio_limit"`
+	SeedingTimeLimit         int     `json:"seeding_time_limit"`
+	InactiveSeedingTimeLimit int     `json:"inactive_seeding_time_limit"`
+	MaxRatio                 float64 `json:"max_ratio"`
+	MaxSeedingTime           int     `json:"max_seeding_time"`
+	MaxInactiveSeedingTime   int     `json:"max_inactive_seeding_time"`
 }
 
 // TorrentFiles
@@ -89,54 +115,92 @@ type TorrentProperties struct {
 }
 
 type AppPreferences struct {
-	SavePath               string  `json:"save_path"`
-	MaxRatioAction         string  `json:"max_ratio_act"`
-	MaxRatio               float64 `json:"max_ratio"`
-	MaxSeedingTime         int     `json:"max_seeding_time"`
-	MaxRatioEnabled        bool    `json:"max_ratio_enabled"`
-	MaxSeedingTimeEnabled  bool    `json:"max_seeding_time_enabled"`
-	QueueingEnabled        bool    `json:"queueing_enabled"`
-	DhtEnabled             bool    `json:"dht"`
-	CreateSubfolderEnabled bool    `json:"create_subfolder_enabled"`
-}
-
-var DummyAppPreferences = AppPreferences{
-	SavePath:               os.Getenv("TRIBLER_DOWNLOAD_DIR"),
-	MaxRatioEnabled:        false,
-	MaxRatio:               0,
-	MaxSeedingTimeEnabled:  false,
-	MaxSeedingTime:         0,
-	MaxRatioAction:         "pause",
-	QueueingEnabled:        true,
-	DhtEnabled:             true,
-	CreateSubfolderEnabled: false,
-}
+	SavePath                      string  `json:"save_path"`
+	MaxRatioAction                int     `json:"max_ratio_act"`
+	MaxRatio                      float64 `json:"max_ratio"`
+	MaxSeedingTime                int     `json:"max_seeding_time"`
+	MaxInactiveSeedingTime        int     `json:"max_inactive_seeding_time"`
+	MaxRatioEnabled               bool    `json:"max_ratio_enabled"`
+	MaxSeedingTimeEnabled         bool    `json:"max_seeding_time_enabled"`
+	MaxInactiveSeedingTimeEnabled bool    `json:"max_inactive_seeding_time_enabled"`
+	QueueingEnabled               bool    `json:"queueing_enabled"`
+	MaxActiveDownloads            int     `json:"max_active_downloads"`
+	MaxActiveUploads              int     `json:"max_active_uploads"`
+	MaxActiveTorrents             int     `json:"max_active_torrents"`
+	DhtEnabled                    bool    `json:"dht"`
+	CreateSubfolderEnabled        bool    `json:"create_subfolder_enabled"`
+	StartPausedEnabled            bool    `json:"start_paused_enabled"`
+	TempPathEnabled               bool    `json:"temp_path_enabled"`
+	TempPath                      string  `json:"temp_path"`
+	DlLimit                       int     `json:"dl_limit"`
+	UpLimit                       int     `json:"up_limit"`
+	ListenPort                    int     `json:"listen_port"`
+	MaxConnec                     int     `json:"max_connec"`
+	BittorrentProtocol            int     `json:"bittorrent_protocol"`
+	Upnp                          bool    `json:"upnp"`
+	Lsd                           bool    `json:"lsd"`
+	TriblerAnonHops               int     `json:"tribler_anon_hops"`
 
-type Handler struct {
-	DB storage.Database
+	BypassLocalAuth                  bool   `json:"bypass_local_auth"`
+	BypassAuthSubnetWhitelistEnabled bool   `json:"bypass_auth_subnet_whitelist_enabled"`
+	BypassAuthSubnetWhitelist        string `json:"bypass_auth_subnet_whitelist"`
+	WebUISessionTimeout              int    `json:"web_ui_session_timeout"`
+	WebUIMaxAuthFailCount            int    `json:"web_ui_max_auth_fail_count"`
+	WebUIBanDuration                 int    `json:"web_ui_ban_duration"`
 }
 
-func NewHandler(db storage.Database) *Handler {
-	return &Handler{DB: db}
+// defaultAppPreferences are the preferences used until they are changed through setPreferences
+var defaultAppPreferences = AppPreferences{
+	MaxRatioEnabled:               false,
+	MaxRatio:                      0,
+	MaxSeedingTimeEnabled:         false,
+	MaxSeedingTime:                0,
+	MaxInactiveSeedingTimeEnabled: false,
+	MaxInactiveSeedingTime:        0,
+	MaxRatioAction:                shareLimitActionPause,
+	QueueingEnabled:               false,
+	MaxActiveDownloads:            3,
+	MaxActiveUploads:              3,
+	MaxActiveTorrents:             5,
+	DhtEnabled:                    true,
+	CreateSubfolderEnabled:        true,
+	StartPausedEnabled:            false,
+	TempPathEnabled:               false,
+	TempPath:                      "",
+
+	BypassLocalAuth:                  false,
+	BypassAuthSubnetWhitelistEnabled: false,
+	BypassAuthSubnetWhitelist:        "",
+	WebUISessionTimeout:              int(defaultSessionTimeout.Seconds()),
+	WebUIMaxAuthFailCount:            defaultMaxAuthFailCount,
+	WebUIBanDuration:                 int(defaultAuthBanDuration.Seconds()),
 }
 
-func (h *Handler) LoginHandler() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Generate a random string of 32 characters
-		sid := "01234678910111213141617"
+type Handler struct {
+	DB            storage.Database
+	credentials   credentials
+	authFailures  *authFailures
+	sync          *syncState
+	peerSync      *syncState
+	sessionTotals *sessionTotals
+	moves         *moves
+	health        *swarmHealth
 
-		// Store the session ID in the database
-		err := h.storeSessionID(sid)
-		if err != nil {
-			return
-		}
-		c.SetCookie("SID", sid, 0, "/", "", false, false)
-		// Set the SID cookie
-		session := sessions.Default(c)
-		session.Set("SID", sid)
-		session.Save()
+	// monitorMu serialises the background monitor with handlers that act on its state
+	monitorMu   sync.Mutex
+	lastMonitor time.Time
+}
 
-		c.String(http.StatusOK, "Ok.")
+func NewHandler(db storage.Database) *Handler {
+	return &Handler{
+		DB:            db,
+		credentials:   loadCredentials(),
+		authFailures:  newAuthFailures(),
+		sync:          newSyncState(),
+		peerSync:      newSyncState(),
+		sessionTotals: newSessionTotals(),
+		moves:         newMoves(),
+		health:        newSwarmHealth(),
 	}
 }
 
@@ -154,50 +218,56 @@ func (h *Handler) GetVersion() gin.HandlerFunc {
 	}
 }
 
-// GetInfo retrieves information about a torrent
+// GetInfo retrieves information about torrents, filtered, sorted and paged like qBittorrent does
 func (h *Handler) GetInfo() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// filter torrents by category
-		filtered_torrents := []Torrent{}
-		// get category
-		category := c.Query("category")
-		// get category_torrents by category
-		category_torrents, err := h.DB.GetTorrentsByCategory(category)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"err": err})
-			return
+		query := infoQuery{
+			filter:  c.Query("filter"),
+			sort:    c.Query("sort"),
+			reverse: c.Query("reverse") == "true",
+			hashes:  map[string]bool{},
 		}
-		downloads, _ := tribler.GetDownloads()
-		converted_downloads := h.ConvertTriblerDownloadstoTorrent(downloads.Downloads)
-
-		// if torrents is empty, return empty json
-		if len(category_torrents) == 0 {
-			c.JSON(http.StatusOK, filtered_torrents)
-			return
+		// a missing category selects every torrent, an empty one only those without a category
+		category, ok := c.GetQuery("category")
+		query.category = category
+		query.anyCategory = !ok || category == "all"
+		tag, ok := c.GetQuery("tag")
+		query.tag = tag
+		query.anyTag = !ok || tag == "all"
+		if hashes := c.Query("hashes"); hashes != "" {
+			for _, hash := range strings.Split(hashes, "|") {
+				query.hashes[strings.ToLower(hash)] = true
+			}
 		}
+		query.limit, _ = strconv.Atoi(c.Query("limit"))
+		query.offset, _ = strconv.Atoi(c.Query("offset"))
 
-		// convert torrents to a map so we can get by hash
-		torrents_map := make(map[string]Torrent)
-		for _, torrent := range converted_downloads {
-			torrents_map[torrent.Hash] = torrent
+		downloads, err := tribler.GetDownloads()
+		if err != nil {
+			handleInternalError(c, "Failed to get downloads", err)
+			return
 		}
-
-		for _, category_torrent := range category_torrents {
-			if torrent, ok := torrents_map[category_torrent.Hash]; ok {
-				torrent.Category = category_torrent.Category
-				filtered_torrents = append(filtered_torrents, torrent)
-			}
+		torrents, err := h.torrentsFromDownloads(downloads.Downloads)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"err": err})
+			return
 		}
 
-		c.JSON(http.StatusOK, filtered_torrents)
+		filtered := filterTorrents(torrents, query)
+		sortTorrents(filtered, query.sort, query.reverse)
+		c.JSON(http.StatusOK, paginate(filtered, query.offset, query.limit))
 	}
 }
 
 // GetAppPreferences retrieves app preferences
 func (h *Handler) GetAppPreferences() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		DummyAppPreferences.SavePath = os.Getenv("TRIBLER_DOWNLOAD_DIR")
-		c.JSON(http.StatusOK, DummyAppPreferences)
+		preferences, err := h.loadPreferences()
+		if err != nil {
+			handleInternalError(c, "Failed to load preferences", err)
+			return
+		}
+		c.JSON(http.StatusOK, withTriblerPreferences(preferences))
 	}
 }
 
@@ -212,7 +282,22 @@ func (h *Handler) GetProperties() gin.HandlerFunc {
 			return
 		}
 		// convert download to the following struct
-		properties := ConvertTriblerDownloadtoTorrentProperties(download)
+		properties, err := h.withTrackedProperties(ConvertTriblerDownloadtoTorrentProperties(download), download)
+		if err != nil {
+			handleInternalError(c, "Failed to get torrent stats", err)
+			return
+		}
+		names, err := h.DB.GetTorrentNames()
+		if err != nil {
+			handleInternalError(c, "Failed to get torrent names", err)
+			return
+		}
+		if name, ok := names[download.Infohash]; ok {
+			properties.Name = name
+		}
+		if location, ok := h.moves.target(download); ok {
+			properties.SavePath = location
+		}
 		c.JSON(http.StatusOK, properties)
 	}
 }
@@ -221,7 +306,7 @@ func (h *Handler) GetProperties() gin.HandlerFunc {
 func (h *Handler) GetTorrentsContents() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		hash := c.Query("hash")
-		torrentFiles, _ := tribler.GetDownloadsFiles(hash)
+		torrentFiles, _ := h.downloadFiles(hash)
 		// convert downloads to the following struct
 		files := ConvertTriblerFilesToTorrentFiles(torrentFiles.Files)
 		// if files is empty, return empty json
@@ -234,6 +319,50 @@ func (h *Handler) GetTorrentsContents() gin.HandlerFunc {
 	}
 }
 
+// resolveHashes splits the pipe separated hashes parameter. "all" resolves to every download known to Tribler.
+func (h *Handler) resolveHashes(hashes string) ([]string, error) {
+	if hashes == "all" {
+		downloads, err := tribler.GetDownloads()
+		if err != nil {
+			return nil, err
+		}
+		all := []string{}
+		for _, download := range downloads.Downloads {
+			all = append(all, download.Infohash)
+		}
+		return all, nil
+	}
+
+	resolved := []string{}
+	for _, hash := range strings.Split(hashes, "|") {
+		hash = strings.ToLower(strings.TrimSpace(hash))
+		if hash != "" {
+			resolved = append(resolved, hash)
+		}
+	}
+	return resolved, nil
+}
+
+// defaultSavePath is where torrents without a category or save path are downloaded to: the save_path
+// preference, which defaults to TRIBLER_DOWNLOAD_DIR
+func (h *Handler) defaultSavePath() string {
+	preferences, err := h.loadPreferences()
+	if err != nil {
+		logbuffer.Warning("Error loading preferences: %+v", err)
+		return os.Getenv("TRIBLER_DOWNLOAD_DIR")
+	}
+	return preferences.SavePath
+}
+
+// categorySavePath returns where torrents of a category are saved. Like qBittorrent, a category without
+// a save path of its own uses a subdirectory named after it in the default save path.
+func categorySavePath(category storage.Category, defaultPath string) string {
+	if category.SavePath != "" {
+		return category.SavePath
+	}
+	return path.Join(defaultPath, category.Name)
+}
+
 func categoryExists(category string, categories []storage.Category) bool {
 	for _, v := range categories {
 		if v.Name == category {
@@ -243,7 +372,7 @@ func categoryExists(category string, categories []storage.Category) bool {
 	return false
 }
 
-// Add adds a new torrent
+// Add adds every torrent from the urls field and the uploaded torrents files
 func (h *Handler) Add() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		urls := c.PostForm("urls")
@@ -251,40 +380,129 @@ func (h *Handler) Add() gin.HandlerFunc {
 		log.Println("torrent.Add urls: ", urls)
 		log.Println("torrent.Add category: ", category)
 
-		urlsLines := strings.Split(urls, "\n")
-		firstURL := urlsLines[0]
-
-		infohash, err := tribler.AddDownload(firstURL)
+		preferences, err := h.loadPreferences()
 		if err != nil {
-			handleInternalError(c, "Error adding torrent", err)
+			handleInternalError(c, "Failed to load preferences", err)
 			return
 		}
-		log.Printf("Response: %s", string(infohash))
 
-		categories, err := h.DB.GetCategories()
-		if err != nil {
-			handleInternalError(c, "Failed to get categories", err)
-			return
-		}
+		destination := c.PostForm("savepath")
+		if category != "" {
+			categories, err := h.DB.GetCategories()
+			if err != nil {
+				handleInternalError(c, "Failed to get categories", err)
+				return
+			}
+
+			if !categoryExists(category, categories) {
+				err = h.DB.AddCategory(category, "")
+				if err != nil {
+					handleInternalError(c, "Failed to add category "+category, err)
+					return
+				}
+			}
 
-		if !categoryExists(category, categories) {
-			err = h.DB.AddCategory(category, os.Getenv("TRIBLER_DOWNLOAD_DIR"))
+			existing, err := h.DB.GetCategory(category)
 			if err != nil {
-				handleInternalError(c, "Failed to add category "+category, err)
+				handleInternalError(c, "Failed to get category "+category, err)
 				return
 			}
+			if destination == "" {
+				destination = categorySavePath(existing, preferences.SavePath)
+			}
+		}
+		if destination == "" {
+			destination = preferences.SavePath
 		}
 
-		torrent := storage.Torrent{
-			Hash:     infohash,
-			Category: category,
+		savePath := destination
+		// incomplete torrents are kept in the temp path and moved to their save path by the monitor
+		if preferences.TempPathEnabled && preferences.TempPath != "" {
+			destination = preferences.TempPath
+		}
+		paused := preferences.StartPausedEnabled
+		if value := c.PostForm("paused"); value != "" {
+			paused = value == "true"
 		}
-		h.DB.AddTorrent(torrent)
+		log.Println("torrent.Add destination: ", destination)
 
-		c.JSON(http.StatusOK, gin.H{"message": "Torrent added"})
+		infohashes := []string{}
+		failed := 0
+		for _, url := range strings.Split(urls, "\n") {
+			url = strings.TrimSpace(url)
+			if url == "" {
+				continue
+			}
+			infohash, err := tribler.AddDownload(url, destination)
+			if err != nil {
+				logbuffer.Warning("Error adding torrent %s: %+v", url, err)
+				failed++
+				continue
+			}
+			infohashes = append(infohashes, infohash)
+		}
+
+		form, err := c.MultipartForm()
+		if err == nil {
+			for _, fileHeader := range form.File["torrents"] {
+				infohash, err := addTorrentFile(fileHeader, destination)
+				if err != nil {
+					logbuffer.Warning("Error adding torrent file %s: %+v", fileHeader.Filename, err)
+					failed++
+					continue
+				}
+				infohashes = append(infohashes, infohash)
+			}
+		}
+
+		for _, infohash := range infohashes {
+			logbuffer.Info("Added torrent %s", infohash)
+			logIgnoredSpeedLimits(c, infohash)
+			if paused {
+				if err := tribler.UpdateDownload(infohash, "stop"); err != nil {
+					logbuffer.Warning("Error pausing %s: %+v", infohash, err)
+				}
+			}
+			if destination != savePath {
+				if err := h.DB.SetTorrentSavePath(infohash, savePath); err != nil {
+					logbuffer.Warning("Error storing save path of %s: %+v", infohash, err)
+				}
+			}
+			if category == "" {
+				continue
+			}
+			err := h.DB.SetTorrentCategory(infohash, category)
+			if err != nil {
+				logbuffer.Warning("Error storing torrent %s: %+v", infohash, err)
+			}
+		}
+
+		// like qBittorrent, a partial success is still a success
+		if len(infohashes) == 0 {
+			c.String(http.StatusOK, "Fails.")
+			return
+		}
+		if failed > 0 {
+			logbuffer.Warning("Failed to add %d of %d torrents", failed, failed+len(infohashes))
+		}
+		c.String(http.StatusOK, "Ok.")
 	}
 }
 
+func addTorrentFile(fileHeader *multipart.FileHeader, destination string) (string, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	return tribler.AddTorrentFile(content, destination)
+}
+
 // Delete deletes a torrent
 func (h *Handler) Delete() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -297,17 +515,61 @@ func (h *Handler) Delete() gin.HandlerFunc {
 			deleteFiles = true
 		}
 
-		tribler.DeleteDownload(hashes, deleteFiles)
-		for _, hash := range strings.Split(hashes, ",") {
-			h.DB.DeleteTorrent(hash)
+		resolved, err := h.resolveHashes(hashes)
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
 		}
+		for _, hash := range resolved {
+			if err := tribler.DeleteDownload(hash, deleteFiles); err != nil {
+				logbuffer.Warning("Error deleting %s: %+v", hash, err)
+			}
+			if err := h.DB.ForgetTorrent(hash); err != nil {
+				logbuffer.Warning("Error forgetting %s: %+v", hash, err)
+			}
+		}
+		c.Status(http.StatusOK)
 	}
 }
 
 // SetCategory sets the category of a torrent
 func (h *Handler) SetCategory() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// TODO: implement setting the category of a torrent in DB
+		category := c.PostForm("category")
+		hashes, err := h.resolveHashes(c.PostForm("hashes"))
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
+		}
+
+		var savePath string
+		if category != "" {
+			existing, err := h.DB.GetCategory(category)
+			if errors.Is(err, storage.ErrCategoryNotFound) {
+				c.JSON(http.StatusConflict, gin.H{"message": "Incorrect category name"})
+				return
+			}
+			if err != nil {
+				handleInternalError(c, "Failed to get category "+category, err)
+				return
+			}
+			savePath = categorySavePath(existing, h.defaultSavePath())
+		}
+
+		moveData := os.Getenv("MOVE_ON_CATEGORY_CHANGE") == "true"
+		for _, hash := range hashes {
+			err = h.DB.SetTorrentCategory(hash, category)
+			if err != nil {
+				handleInternalError(c, "Failed to set category of "+hash, err)
+				return
+			}
+			if moveData && savePath != "" {
+				if err := h.moveTorrent(hash, savePath); err != nil {
+					logbuffer.Warning("Error moving %s: %+v", hash, err)
+				}
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{"message": "Torrent category set"})
 	}
 }
@@ -339,68 +601,212 @@ func (h *Handler) GetCategories() gin.HandlerFunc {
 		//   }
 		// }
 
-		categoryMap := make(map[string]map[string]string)
-		for _, category := range categories {
-			categoryMap[category.Name] = map[string]string{
-				"savePath": category.SavePath,
-				"name":     category.Name,
-			}
-		}
-		c.JSON(http.StatusOK, categoryMap)
+		c.JSON(http.StatusOK, categoriesToMap(categories, h.defaultSavePath()))
 	}
 }
 
-// SetShareLimits sets the share limits of a torrent
-func (h *Handler) SetShareLimits() gin.HandlerFunc {
+// EditCategory changes the save path of a category
+func (h *Handler) EditCategory() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// TODO: implement setting the share limits of a torrent in DB
-		c.JSON(http.StatusOK, gin.H{"message": "Torrent share limits set"})
+		category := c.PostForm("category")
+		if category == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Category name is empty"})
+			return
+		}
+
+		err := h.DB.EditCategory(category, c.PostForm("savePath"))
+		if errors.Is(err, storage.ErrCategoryNotFound) {
+			c.JSON(http.StatusConflict, gin.H{"message": "Category editing failed"})
+			return
+		}
+		if err != nil {
+			handleInternalError(c, "Failed to edit category "+category, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Category edited"})
 	}
 }
 
-// SetTopPriority sets the priority of a torrent to top
-func (h *Handler) SetTopPriority() gin.HandlerFunc {
+// RemoveCategories removes the newline separated categories. Their torrents end up without a category.
+func (h *Handler) RemoveCategories() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// TODO: implement setting the priority of a torrent to top in DB
-		c.JSON(http.StatusOK, gin.H{"message": "Torrent priority set to top"})
+		categories := []string{}
+		for _, category := range strings.Split(c.PostForm("categories"), "\n") {
+			category = strings.TrimSpace(category)
+			if category != "" {
+				categories = append(categories, category)
+			}
+		}
+
+		err := h.DB.RemoveCategories(categories)
+		if err != nil {
+			handleInternalError(c, "Failed to remove categories", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Categories removed"})
 	}
 }
 
+func categoriesToMap(categories []storage.Category, defaultPath string) map[string]map[string]string {
+	categoryMap := make(map[string]map[string]string)
+	for _, category := range categories {
+		categoryMap[category.Name] = map[string]string{
+			"savePath": categorySavePath(category, defaultPath),
+			"name":     category.Name,
+		}
+	}
+	return categoryMap
+}
+
+// categoriesMap returns all categories keyed by name, the way qBittorrent returns them
+func (h *Handler) categoriesMap() (map[string]map[string]string, error) {
+	categories, err := h.DB.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+	return categoriesToMap(categories, h.defaultSavePath()), nil
+}
+
 // PauseTorrent pauses a torrent
 func (h *Handler) PauseTorrent() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// get hashes
-		hashes := c.PostForm("hashes")
-		tribler.UpdateDownload(hashes, "stop")
+		hashes, err := h.resolveHashes(c.PostForm("hashes"))
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
+		}
+		for _, hash := range hashes {
+			if err := tribler.UpdateDownload(hash, "stop"); err != nil {
+				logbuffer.Warning("Error pausing %s: %+v", hash, err)
+			}
+		}
+		// paused torrents are no longer waiting in the queue
+		if err := h.DB.SetQueued(hashes, false); err != nil {
+			logbuffer.Warning("Error updating queue: %+v", err)
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Torrent paused"})
 	}
 }
 
-// ResumeTorrent resumes a torrent
+// ResumeTorrent resumes a torrent. With queueing enabled the torrent is queued and started once there is a free slot.
 func (h *Handler) ResumeTorrent() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// get hashes
-		hashes := c.PostForm("hashes")
-		tribler.UpdateDownload(hashes, "resume")
+		hashes, err := h.resolveHashes(c.PostForm("hashes"))
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
+		}
+		hashes, refused, err := h.withoutRenamedFiles(hashes)
+		if err != nil {
+			handleInternalError(c, "Failed to get renamed torrents", err)
+			return
+		}
+		preferences, err := h.loadPreferences()
+		if err != nil {
+			handleInternalError(c, "Failed to load preferences", err)
+			return
+		}
+		// like qBittorrent, a regular resume takes away force start
+		if err := h.DB.SetForceStart(hashes, false); err != nil {
+			logbuffer.Warning("Error clearing force start: %+v", err)
+		}
+
+		if preferences.QueueingEnabled {
+			if err := h.DB.SetQueued(hashes, true); err != nil {
+				handleInternalError(c, "Failed to queue torrents", err)
+				return
+			}
+			h.refreshQueue()
+		} else {
+			for _, hash := range hashes {
+				if err := tribler.UpdateDownload(hash, "resume"); err != nil {
+					logbuffer.Warning("Error resuming %s: %+v", hash, err)
+				}
+			}
+		}
+		if len(refused) > 0 {
+			refuseRenamedFiles(c, refused)
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Torrent resumed"})
 	}
 }
 
-// SetForceStartTorrent sets a torrent to force start
+// SetForceStartTorrent sets or clears force start. Force started torrents are resumed right away and ignore
+// the queue and share limits.
 func (h *Handler) SetForceStartTorrent() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// TODO: implement setting a torrent to force start in DB
+		forceStart := c.PostForm("value") == "true"
+		hashes, err := h.resolveHashes(c.PostForm("hashes"))
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
+		}
+		var refused []string
+		if forceStart {
+			hashes, refused, err = h.withoutRenamedFiles(hashes)
+			if err != nil {
+				handleInternalError(c, "Failed to get renamed torrents", err)
+				return
+			}
+		}
+		if err := h.DB.SetForceStart(hashes, forceStart); err != nil {
+			handleInternalError(c, "Failed to set force start", err)
+			return
+		}
+
+		if forceStart {
+			if err := h.DB.SetQueued(hashes, false); err != nil {
+				logbuffer.Warning("Error updating queue: %+v", err)
+			}
+			for _, hash := range hashes {
+				if err := tribler.UpdateDownload(hash, "resume"); err != nil {
+					logbuffer.Warning("Error resuming %s: %+v", hash, err)
+				}
+			}
+		}
+		h.refreshQueue()
+		if len(refused) > 0 {
+			refuseRenamedFiles(c, refused)
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Torrent set to force start"})
 	}
 }
 
+// RecheckTorrent asks Tribler to check the data of torrents against their hashes
+func (h *Handler) RecheckTorrent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hashes, err := h.resolveHashes(c.PostForm("hashes"))
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
+		}
+		hashes, refused, err := h.withoutRenamedFiles(hashes)
+		if err != nil {
+			handleInternalError(c, "Failed to get renamed torrents", err)
+			return
+		}
+		for _, hash := range hashes {
+			if err := tribler.UpdateDownload(hash, "recheck"); err != nil {
+				logbuffer.Warning("Error rechecking %s: %+v", hash, err)
+			}
+		}
+		if len(refused) > 0 {
+			refuseRenamedFiles(c, refused)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Torrent rechecked"})
+	}
+}
+
 // CreateCategory creates a new category
 func (h *Handler) CreateCategory() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// get category
 		category := c.PostForm("category")
-		// savePath is the same as the default download directory
-		savePath := os.Getenv("TRIBLER_DOWNLOAD_DIR")
+		// an empty savePath is resolved to a subdirectory of the default download directory
+		savePath := c.PostForm("savePath")
 		// check if category already exists
 		categories, err := h.DB.GetCategories()
 		if err != nil {
@@ -426,99 +832,273 @@ func (h *Handler) CreateCategory() gin.HandlerFunc {
 	}
 }
 
-func (h *Handler) storeSessionID(_ string) error {
-	// TODO: implement storing the session ID in the database
-	return nil
+// torrentsFromDownloads converts Tribler downloads to torrents with the category and tags stored in the
+// database. Torrents that are not in the database have no category.
+func (h *Handler) torrentsFromDownloads(downloads []tribler.Download) ([]Torrent, error) {
+	stored, err := h.DB.GetAllTorrents()
+	if err != nil {
+		return nil, err
+	}
+	categories := make(map[string]string)
+	for _, torrent := range stored {
+		categories[torrent.Hash] = torrent.Category
+	}
+
+	tags, err := h.DB.GetTorrentTags()
+	if err != nil {
+		return nil, err
+	}
+	shareLimits, err := h.DB.GetShareLimits()
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := h.loadPreferences()
+	if err != nil {
+		return nil, err
+	}
+	queue, err := h.DB.GetQueue()
+	if err != nil {
+		return nil, err
+	}
+	queued := map[string]bool{}
+	for _, entry := range queue {
+		queued[entry.Hash] = entry.Queued
+	}
+	priorities := queuePriorities(queue, downloads, preferences.QueueingEnabled)
+	forced, err := h.DB.GetForceStarted()
+	if err != nil {
+		return nil, err
+	}
+	stats, err := h.DB.GetTorrentStats()
+	if err != nil {
+		return nil, err
+	}
+	names, err := h.DB.GetTorrentNames()
+	if err != nil {
+		return nil, err
+	}
+
+	torrents := h.ConvertTriblerDownloadstoTorrent(downloads)
+	for i := range torrents {
+		hash := torrents[i].Hash
+		torrents[i].Category = categories[hash]
+		torrents[i].Tags = strings.Join(tags[hash], ", ")
+		if name, ok := names[hash]; ok {
+			torrents[i].Name = name
+		}
+		torrents[i].Priority = priorities[hash]
+		torrents[i].ForceStart = forced[hash]
+		if state := forcedState(downloads[i]); forced[hash] && state != "" {
+			torrents[i].State = state
+		}
+		if queued[hash] && !isRunning(downloads[i]) {
+			if downloads[i].Progress >= 1 {
+				torrents[i].State = "queuedUP"
+			} else {
+				torrents[i].State = "queuedDL"
+			}
+		}
+
+		if location, ok := h.moves.target(downloads[i]); ok {
+			torrents[i].State = "moving"
+			torrents[i].SavePath = location
+		}
+		if s, ok := stats[hash]; ok {
+			if s.CompletedOn > 0 {
+				torrents[i].CompletionOn = int(s.CompletedOn)
+			}
+			if s.LastActivity > 0 {
+				torrents[i].LastActivity = int(s.LastActivity)
+			}
+			torrents[i].SeedingTime = int(s.SeedingTime)
+		}
+
+		limits, ok := shareLimits[hash]
+		if !ok {
+			limits = defaultShareLimits(hash)
+		}
+		effective := effectiveShareLimits(limits, preferences)
+		torrents[i].RatioLimit = limits.RatioLimit
+		torrents[i].SeedingTimeLimit = limits.SeedingTimeLimit
+		torrents[i].InactiveSeedingTimeLimit = limits.InactiveSeedingTimeLimit
+		torrents[i].MaxRatio = effective.RatioLimit
+		torrents[i].MaxSeedingTime = effective.SeedingTimeLimit
+		torrents[i].MaxInactiveSeedingTime = effective.InactiveSeedingTimeLimit
+	}
+	return torrents, nil
 }
 
 func (h *Handler) ConvertTriblerDownloadstoTorrent(downloads []tribler.Download) []Torrent {
 	// Convert tribler download to torrent
 	torrent := []Torrent{}
 	for _, download := range downloads {
-		var state string
-		switch download.Status {
-		case "SEEDING":
-			state = "pausedUP"
-		case "DOWNLOADING":
-			state = "downloading"
-		case "PAUSED":
-			state = "pausedUP"
+		seeds, leechs := h.swarmTotals(download)
+		amountLeft := int(float64(download.Size) * (1 - download.Progress))
+		if amountLeft < 0 {
+			amountLeft = 0
 		}
 		torrent = append(torrent, Torrent{
 			Dlspeed:       download.SpeedDown,
-			Eta:           download.Eta,
+			Eta:           torrentEta(download),
 			FLPiecePrio:   false,
 			ForceStart:    false,
 			Hash:          download.Infohash,
 			Category:      os.Getenv("DEFAULT_CATEGORY"),
 			Tags:          "",
 			Name:          download.Name,
-			NumComplete:   download.NumPeers,
-			NumIncomplete: download.NumPeers,
+			NumComplete:   seeds,
+			NumIncomplete: leechs,
 			NumLeechs:     download.NumPeers,
-			NumSeeds:      download.NumPeers,
+			NumSeeds:      download.NumSeeds,
 			Priority:      0,
 			Progress:      download.Progress,
-			Ratio:         0,
+			Ratio:         download.AllTimeRatio,
 			SeqDL:         false,
 			Size:          download.Size,
 			ContentPath:   download.Destination + "/" + download.Name,
-			State:         state,
+			State:         torrentState(download),
 			SuperSeeding:  false,
 			Upspeed:       download.SpeedUp,
+			DlLimit:       speedLimit(download.MaxDownloadSpeed),
+			UpLimit:       speedLimit(download.MaxUploadSpeed),
+			AddedOn:       download.TimeAdded,
+			CompletionOn:  -1,
+			AmountLeft:    amountLeft,
+			Downloaded:    int(download.AllTimeDownload),
+			Uploaded:      int(download.AllTimeUpload),
+			SavePath:      download.Destination,
+			LastActivity:  0,
+			Availability:  download.Availability,
+			Tracker:       currentTracker(download.Trackers),
 		})
 	}
 	return torrent
 }
 
+// swarmTotals estimates the number of seeds and leechers in the swarm from the connected peers, the last health
+// check and the number of peers each tracker knows of. Tracker peers beyond the seeds are counted as leechers.
+func (h *Handler) swarmTotals(download tribler.Download) (int, int) {
+	health := h.health.get(download.Infohash)
+	trackerPeers := 0
+	for _, tracker := range download.Trackers {
+		if !isPseudoTracker(tracker.Url) {
+			trackerPeers = max(trackerPeers, tracker.Peers)
+		}
+	}
+	complete := max(download.NumSeeds, health.seeders)
+	incomplete := max(download.NumPeers, health.leechers, trackerPeers-complete)
+	return complete, incomplete
+}
+
+// currentTracker returns the first working tracker like qBittorrent, or an empty string
+func currentTracker(trackers []tribler.Trackers) string {
+	for _, tracker := range trackers {
+		if isPseudoTracker(tracker.Url) {
+			continue
+		}
+		if status, _ := trackerStatus(tracker.Status); status == trackerWorking {
+			return tracker.Url
+		}
+	}
+	return ""
+}
+
 func (h *Handler) containsFileExtensionSuffix(s string) bool {
 	re := regexp.MustCompile(`\.[a-z0-9]{3}$`)
 	return re.MatchString(s)
 }
 
+// ConvertTriblerDownloadtoTorrentProperties fills in the properties Tribler reports. The ones tracked by the
+// shim are added by withTrackedProperties.
 func ConvertTriblerDownloadtoTorrentProperties(download tribler.Download) TorrentProperties {
-	destination := download.Destination
-	// if !containsFileExtensionSuffix(download.Name) {
-	// 	log.Println("Does not contain extension = ", download.Name)
-	// 	destination = download.Destination + "/" + download.Name
-	// }
-
 	return TorrentProperties{
-		SavePath:               destination,
+		SavePath:               download.Destination,
 		Name:                   download.Name,
-		CreationDate:           0,
-		PieceSize:              0,
+		CreationDate:           -1,
+		PieceSize:              pieceSize(download),
 		Comment:                "",
 		TotalWasted:            0,
-		TotalUploaded:          0,
+		TotalUploaded:          int(download.AllTimeUpload),
 		TotalUploadedSession:   0,
-		TotalDownloaded:        0,
+		TotalDownloaded:        int(download.AllTimeDownload),
 		TotalDownloadedSession: 0,
-		UpLimit:                0,
-		DlLimit:                0,
+		UpLimit:                speedLimit(download.MaxUploadSpeed),
+		DlLimit:                speedLimit(download.MaxDownloadSpeed),
 		TimeElapsed:            0,
 		SeedingTime:            0,
-		NbConnections:          0,
-		NbConnectionsLimit:     0,
-		ShareRatio:             0,
-		AdditionDate:           0,
-		CompletionDate:         0,
+		NbConnections:          download.NumSeeds + download.NumPeers,
+		NbConnectionsLimit:     -1,
+		ShareRatio:             download.AllTimeRatio,
+		AdditionDate:           download.TimeAdded,
+		CompletionDate:         -1,
 		CreatedBy:              "",
 		DlSpeedAvg:             0,
-		DlSpeed:                0,
-		Eta:                    0,
-		LastSeen:               0,
-		Peers:                  0,
-		PeersTotal:             0,
-		PiecesHave:             0,
-		PiecesNum:              0,
+		DlSpeed:                download.SpeedDown,
+		Eta:                    torrentEta(download),
+		LastSeen:               -1,
+		Peers:                  download.NumPeers,
+		PeersTotal:             download.NumPeers,
+		PiecesHave:             int(download.Progress * float64(download.TotalPieces)),
+		PiecesNum:              download.TotalPieces,
 		Reannounce:             0,
-		Seeds:                  0,
-		SeedsTotal:             0,
-		TotalSize:              0,
+		Seeds:                  download.NumSeeds,
+		SeedsTotal:             download.NumSeeds,
+		TotalSize:              download.Size,
 		UpSpeedAvg:             0,
-		UpSpeed:                0,
+		UpSpeed:                download.SpeedUp,
+	}
+}
+
+// withTrackedProperties adds the properties the shim tracks itself because Tribler does not report them
+func (h *Handler) withTrackedProperties(properties TorrentProperties, download tribler.Download) (TorrentProperties, error) {
+	properties.TotalDownloadedSession, properties.TotalUploadedSession = h.sessionTotals.session(download)
+	properties.SeedsTotal, properties.PeersTotal = h.swarmTotals(download)
+
+	stats, err := h.DB.GetTorrentStats()
+	if err != nil {
+		return properties, err
+	}
+	s, ok := stats[download.Infohash]
+	if !ok {
+		return properties, nil
+	}
+	properties.TimeElapsed = int(s.ActiveTime)
+	properties.SeedingTime = int(s.SeedingTime)
+	if s.ActiveTime > 0 {
+		properties.DlSpeedAvg = int(download.AllTimeDownload / float64(s.ActiveTime))
+		properties.UpSpeedAvg = int(download.AllTimeUpload / float64(s.ActiveTime))
+	}
+	if s.CompletedOn > 0 {
+		properties.CompletionDate = int(s.CompletedOn)
+	}
+	if s.LastSeenComplete > 0 {
+		properties.LastSeen = int(s.LastSeenComplete)
+	}
+	return properties, nil
+}
+
+// infiniteEta is what qBittorrent reports as the ETA of torrents that will not complete
+const infiniteEta = 8640000
+
+// torrentEta returns the seconds until a download completes, infiniteEta when that is not going to happen
+func torrentEta(download tribler.Download) int {
+	if download.Progress >= 1 || download.SpeedDown <= 0 || download.Eta <= 0 || download.Eta > infiniteEta {
+		return infiniteEta
+	}
+	return int(download.Eta)
+}
+
+// pieceSize derives the piece size from the size and number of pieces. Piece sizes are powers of two and
+// only the last piece can be smaller.
+func pieceSize(download tribler.Download) int {
+	if download.TotalPieces <= 0 || download.Size <= 0 {
+		return 0
+	}
+	size := 1
+	for size*download.TotalPieces < download.Size {
+		size *= 2
 	}
+	return size
 }
 
 func ConvertTriblerFilesToTorrentFiles(files []tribler.Files) []TorrentFiles {
@@ -529,7 +1109,7 @@ func ConvertTriblerFilesToTorrentFiles(files []tribler.Files) []TorrentFiles {
 			Name:         file.Name,
 			Size:         file.Size,
 			Progress:     file.Progress,
-			Priority:     0,
+			Priority:     filePriority(file),
 			IsSeed:       false,
 			PieceRange:   []int{},
 			Availability: 0,
@@ -540,7 +1120,7 @@ func ConvertTriblerFilesToTorrentFiles(files []tribler.Files) []TorrentFiles {
 
 func handleInternalError(c *gin.Context, msg string, err error) {
 	c.JSON(http.StatusInternalServerError, gin.H{"message": msg})
-	log.Printf("Error: %+v", err)
+	logbuffer.Critical("%s: %+v", msg, err)
 }
 
 func ImportNonCategorisedTorrents(db storage.Database, category string) error {
@@ -572,14 +1152,14 @@ func ImportNonCategorisedTorrents(db storage.Database, category string) error {
 	for _, download := range downloads_response.Downloads {
 		log.Println("Processing", download.Infohash, download.Name)
 		if _, exists := hashes[download.Infohash]; !exists {
-			log.Printf("Importing torrent %s", download.Infohash)
+			logbuffer.Info("Importing torrent %s", download.Infohash)
 			new_torrent := storage.Torrent{
 				Hash:     download.Infohash,
 				Category: category,
 			}
 			err := db.AddTorrent(new_torrent)
 			if err != nil {
-				log.Printf("Error adding torrent: %+v", err)
+				logbuffer.Warning("Error adding torrent: %+v", err)
 			}
 		}
 	}
@@ -0,0 +1,221 @@
+package language
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// qBittorrent tracker statuses
+const (
+	trackerDisabled     = 0
+	trackerNotContacted = 1
+	trackerWorking      = 2
+	trackerUpdating     = 3
+	trackerNotWorking   = 4
+)
+
+// Tracker is a row of /torrents/trackers
+type Tracker struct {
+	URL           string `json:"url"`
+	Status        int    `json:"status"`
+	Tier          int    `json:"tier"`
+	NumPeers      int    `json:"num_peers"`
+	NumSeeds      int    `json:"num_seeds"`
+	NumLeeches    int    `json:"num_leeches"`
+	NumDownloaded int    `json:"num_downloaded"`
+	Msg           string `json:"msg"`
+}
+
+// isPseudoTracker tells whether a Tribler tracker entry stands for DHT, PeX or LSD rather than a real tracker
+func isPseudoTracker(trackerURL string) bool {
+	return strings.HasPrefix(trackerURL, "[") && strings.HasSuffix(trackerURL, "]")
+}
+
+// trackerStatus maps Tribler's tracker status string to a qBittorrent status code and message
+func trackerStatus(status string) (int, string) {
+	switch {
+	case status == "Working":
+		return trackerWorking, ""
+	case status == "Not contacted":
+		return trackerNotContacted, ""
+	case status == "Disabled":
+		return trackerDisabled, ""
+	case strings.HasPrefix(status, "Updating"):
+		return trackerUpdating, ""
+	case status == "Not working", status == "Timeout":
+		return trackerNotWorking, status
+	case strings.HasPrefix(status, "Error"):
+		return trackerNotWorking, strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(status, "Error"), ":"))
+	}
+	return trackerNotContacted, status
+}
+
+func convertTriblerTrackers(trackers []tribler.Trackers) []Tracker {
+	converted := []Tracker{}
+	for _, tracker := range trackers {
+		status, msg := trackerStatus(tracker.Status)
+		row := Tracker{
+			URL:           tracker.Url,
+			Status:        status,
+			NumPeers:      tracker.Peers,
+			NumSeeds:      -1,
+			NumLeeches:    -1,
+			NumDownloaded: -1,
+			Msg:           msg,
+		}
+		if isPseudoTracker(tracker.Url) {
+			// qBittorrent lists DHT, PeX and LSD first, outside of any tier
+			row.URL = "** " + tracker.Url + " **"
+			row.Tier = -1
+		}
+		converted = append(converted, row)
+	}
+	return converted
+}
+
+func hasTracker(trackers []tribler.Trackers, trackerURL string) bool {
+	for _, tracker := range trackers {
+		if tracker.Url == trackerURL {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTrackers retrieves the trackers of a torrent
+func (h *Handler) GetTrackers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash := strings.ToLower(c.Query("hash"))
+		download, err := tribler.GetDownload(hash)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Torrent not found"})
+			return
+		}
+		c.JSON(http.StatusOK, convertTriblerTrackers(download.Trackers))
+	}
+}
+
+// AddTrackers adds newline separated tracker urls to a torrent
+func (h *Handler) AddTrackers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash := strings.ToLower(c.PostForm("hash"))
+		download, err := tribler.GetDownload(hash)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Torrent not found"})
+			return
+		}
+		for _, trackerURL := range strings.Split(c.PostForm("urls"), "\n") {
+			trackerURL = strings.TrimSpace(trackerURL)
+			if trackerURL == "" || hasTracker(download.Trackers, trackerURL) {
+				continue
+			}
+			err = tribler.AddTracker(hash, trackerURL)
+			if err != nil {
+				logbuffer.Warning("Error adding tracker %s to %s: %+v", trackerURL, hash, err)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Trackers added"})
+	}
+}
+
+// EditTracker replaces a tracker url of a torrent
+func (h *Handler) EditTracker() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash := strings.ToLower(c.PostForm("hash"))
+		origURL := c.PostForm("origUrl")
+		newURL := c.PostForm("newUrl")
+		parsed, err := url.Parse(newURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid newUrl"})
+			return
+		}
+
+		download, err := tribler.GetDownload(hash)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Torrent not found"})
+			return
+		}
+		if !hasTracker(download.Trackers, origURL) {
+			c.JSON(http.StatusConflict, gin.H{"message": "origUrl not found"})
+			return
+		}
+		if hasTracker(download.Trackers, newURL) {
+			c.JSON(http.StatusConflict, gin.H{"message": "newUrl already exists"})
+			return
+		}
+
+		err = tribler.AddTracker(hash, newURL)
+		if err != nil {
+			handleInternalError(c, "Failed to add tracker", err)
+			return
+		}
+		err = tribler.RemoveTracker(hash, origURL)
+		if err != nil {
+			handleInternalError(c, "Failed to remove tracker", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Tracker edited"})
+	}
+}
+
+// RemoveTrackers removes pipe separated tracker urls from a torrent
+func (h *Handler) RemoveTrackers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hash := strings.ToLower(c.PostForm("hash"))
+		download, err := tribler.GetDownload(hash)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Torrent not found"})
+			return
+		}
+
+		removed := 0
+		for _, trackerURL := range strings.Split(c.PostForm("urls"), "|") {
+			if !hasTracker(download.Trackers, trackerURL) {
+				continue
+			}
+			err = tribler.RemoveTracker(hash, trackerURL)
+			if err != nil {
+				handleInternalError(c, "Failed to remove tracker "+trackerURL, err)
+				return
+			}
+			removed++
+		}
+		if removed == 0 {
+			c.JSON(http.StatusConflict, gin.H{"message": "None of the trackers were found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Trackers removed"})
+	}
+}
+
+// Reannounce makes torrents announce to all of their trackers
+func (h *Handler) Reannounce() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hashes, err := h.resolveHashes(c.PostForm("hashes"))
+		if err != nil {
+			handleInternalError(c, "Failed to resolve hashes", err)
+			return
+		}
+		for _, hash := range hashes {
+			download, err := tribler.GetDownload(hash)
+			if err != nil {
+				logbuffer.Warning("Error getting download %s: %+v", hash, err)
+				continue
+			}
+			for _, tracker := range download.Trackers {
+				if isPseudoTracker(tracker.Url) {
+					continue
+				}
+				if err := tribler.ForceAnnounce(hash, tracker.Url); err != nil {
+					logbuffer.Warning("Error reannouncing %s to %s: %+v", hash, tracker.Url, err)
+				}
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Torrent reannounced"})
+	}
+}
@@ -0,0 +1,183 @@
+package language
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"tribler-arr-shim/pkg/logbuffer"
+	"tribler-arr-shim/pkg/tribler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TransferInfo is returned by /transfer/info and is part of the maindata server_state
+type TransferInfo struct {
+	ConnectionStatus string `json:"connection_status"`
+	DlInfoSpeed      int    `json:"dl_info_speed"`
+	DlInfoData       int    `json:"dl_info_data"`
+	UpInfoSpeed      int    `json:"up_info_speed"`
+	UpInfoData       int    `json:"up_info_data"`
+	DlRateLimit      int    `json:"dl_rate_limit"`
+	UpRateLimit      int    `json:"up_rate_limit"`
+	DhtNodes         int    `json:"dht_nodes"`
+}
+
+type transferTotals struct {
+	downloaded float64
+	uploaded   float64
+}
+
+// sessionTotals remembers the all time totals of every download when the shim first saw it, so that the
+// data transferred during this session can be told apart. Downloads added later start from zero.
+type sessionTotals struct {
+	mu       sync.Mutex
+	started  bool
+	baseline map[string]transferTotals
+}
+
+func newSessionTotals() *sessionTotals {
+	return &sessionTotals{baseline: map[string]transferTotals{}}
+}
+
+// session returns the data downloaded and uploaded by a download during this session
+func (s *sessionTotals) session(download tribler.Download) (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	baseline, ok := s.baseline[download.Infohash]
+	if !ok {
+		if s.started {
+			baseline = transferTotals{}
+		} else {
+			baseline = transferTotals{downloaded: download.AllTimeDownload, uploaded: download.AllTimeUpload}
+		}
+		s.baseline[download.Infohash] = baseline
+	}
+	downloaded := download.AllTimeDownload - baseline.downloaded
+	uploaded := download.AllTimeUpload - baseline.uploaded
+	if downloaded < 0 {
+		downloaded = 0
+	}
+	if uploaded < 0 {
+		uploaded = 0
+	}
+	return int(downloaded), int(uploaded)
+}
+
+// start marks the end of the first observation; downloads seen after it were added during this session
+func (s *sessionTotals) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = true
+}
+
+// kibToBytes converts a Tribler rate in KiB/s to the bytes per second qBittorrent uses
+func kibToBytes(rate int) int {
+	return rate * 1024
+}
+
+// bytesToKib converts a qBittorrent rate in bytes per second to KiB/s for Tribler. Limits below 1 KiB/s are
+// rounded up, as 0 would mean unlimited.
+func bytesToKib(limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	if limit < 1024 {
+		return 1
+	}
+	return limit / 1024
+}
+
+// transferInfo aggregates the downloads and reads the global rate limits and DHT nodes from Tribler
+func (h *Handler) transferInfo(downloads []tribler.Download) TransferInfo {
+	info := TransferInfo{ConnectionStatus: "connected"}
+	for _, download := range downloads {
+		info.DlInfoSpeed += download.SpeedDown
+		info.UpInfoSpeed += download.SpeedUp
+		downloaded, uploaded := h.sessionTotals.session(download)
+		info.DlInfoData += downloaded
+		info.UpInfoData += uploaded
+	}
+	h.sessionTotals.start()
+
+	settings, err := tribler.GetSettings()
+	if err != nil {
+		logbuffer.Warning("Error getting Tribler settings: %+v", err)
+	}
+	info.DlRateLimit = kibToBytes(settings.Libtorrent.MaxDownloadRate)
+	info.UpRateLimit = kibToBytes(settings.Libtorrent.MaxUploadRate)
+
+	session, err := tribler.GetLibtorrentSession(0)
+	if err != nil {
+		logbuffer.Warning("Error getting libtorrent session: %+v", err)
+	}
+	info.DhtNodes = int(session.Session["dht.dht_nodes"])
+	return info
+}
+
+// GetTransferInfo retrieves global transfer information
+func (h *Handler) GetTransferInfo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		downloads, err := tribler.GetDownloads()
+		if err != nil {
+			logbuffer.Warning("Error getting downloads: %+v", err)
+			c.JSON(http.StatusOK, TransferInfo{ConnectionStatus: "disconnected"})
+			return
+		}
+		c.JSON(http.StatusOK, h.transferInfo(downloads.Downloads))
+	}
+}
+
+// GetDownloadLimit retrieves the global download limit in bytes per second, 0 if unlimited
+func (h *Handler) GetDownloadLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings, err := tribler.GetSettings()
+		if err != nil {
+			handleInternalError(c, "Failed to get Tribler settings", err)
+			return
+		}
+		c.String(http.StatusOK, strconv.Itoa(kibToBytes(settings.Libtorrent.MaxDownloadRate)))
+	}
+}
+
+// GetUploadLimit retrieves the global upload limit in bytes per second, 0 if unlimited
+func (h *Handler) GetUploadLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings, err := tribler.GetSettings()
+		if err != nil {
+			handleInternalError(c, "Failed to get Tribler settings", err)
+			return
+		}
+		c.String(http.StatusOK, strconv.Itoa(kibToBytes(settings.Libtorrent.MaxUploadRate)))
+	}
+}
+
+// setGlobalRateLimit sets one of Tribler's libtorrent rate limits from the limit form field
+func setGlobalRateLimit(c *gin.Context, setting string) {
+	limit, err := strconv.Atoi(c.PostForm("limit"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid limit"})
+		return
+	}
+	err = tribler.UpdateSettings(map[string]interface{}{
+		"libtorrent": map[string]interface{}{setting: bytesToKib(limit)},
+	})
+	if err != nil {
+		handleInternalError(c, "Failed to update Tribler settings", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Limit set"})
+}
+
+// SetDownloadLimit sets the global download limit in bytes per second
+func (h *Handler) SetDownloadLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setGlobalRateLimit(c, "max_download_rate")
+	}
+}
+
+// SetUploadLimit sets the global upload limit in bytes per second
+func (h *Handler) SetUploadLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		setGlobalRateLimit(c, "max_upload_rate")
+	}
+}
@@ -28,14 +28,14 @@ type Download struct {
 	TimeAdded        int        `json:"time_added"`
 	Size             int        `json:"size"`
 	AllTimeDownload  float64    `json:"all_time_download"`
-	Availability     int        `json:"availability"`
+	Availability     float64    `json:"availability"`
 	SafeSeeding      bool       `json:"safe_seeding"`
 	Name             string     `json:"name"`
 	MaxDownloadSpeed int        `json:"max_download_speed"`
 	Status           string     `json:"status"`
 	Infohash         string     `json:"infohash"`
 	MaxUploadSpeed   int        `json:"max_upload_speed"`
-	Peers            string     `json:"peers"`
+	Peers            Peers      `json:"peers"`
 	Trackers         []Trackers `json:"trackers"`
 	AnonDownload     bool       `json:"anon_download"`
 	Error            string     `json:"error"`
@@ -45,12 +45,64 @@ type Download struct {
 	SpeedUp          int        `json:"speed_up"`
 }
 
+// Peer is a peer of a download as reported with get_peers=1
+type Peer struct {
+	IP              string  `json:"ip"`
+	Port            int     `json:"port"`
+	ID              string  `json:"id"`
+	ExtendedVersion string  `json:"extended_version"`
+	Completed       float64 `json:"completed"`
+	Downrate        float64 `json:"downrate"`
+	Uprate          float64 `json:"uprate"`
+	Dtotal          float64 `json:"dtotal"`
+	Utotal          float64 `json:"utotal"`
+	Seed            bool    `json:"seed"`
+	Direction       string  `json:"direction"`
+	ConnectionType  int     `json:"connection_type"`
+	Optimistic      bool    `json:"optimistic"`
+	PexReceived     bool    `json:"pex_received"`
+	Snubbed         bool    `json:"snubbed"`
+	Uinterested     bool    `json:"uinterested"`
+	Uchoked         bool    `json:"uchoked"`
+	Dinterested     bool    `json:"dinterested"`
+	Dchoked         bool    `json:"dchoked"`
+}
+
+// Peers is the peer list of a download. Tribler reports an empty string instead of a list unless
+// peers were requested.
+type Peers []Peer
+
+// UnmarshalJSON accepts both a peer list and the string Tribler sends in its place
+func (p *Peers) UnmarshalJSON(data []byte) error {
+	var list []Peer
+	if err := json.Unmarshal(data, &list); err != nil {
+		var s string
+		if json.Unmarshal(data, &s) != nil {
+			return err
+		}
+		list = nil
+	}
+	*p = list
+	return nil
+}
+
 type Trackers struct {
 	Url    string `json:"url"`
 	Peers  int    `json:"peers"`
 	Status string `json:"status"`
 }
 
+// TrackerHealth is what a tracker, or the DHT, reports about the swarm of a torrent
+type TrackerHealth struct {
+	Seeders  int    `json:"seeders"`
+	Leechers int    `json:"leechers"`
+	Error    string `json:"error"`
+}
+
+type TorrentHealthResponse struct {
+	Health map[string]TrackerHealth `json:"health"`
+}
+
 type Checkpoints struct {
 	Loaded    int  `json:"loaded"`
 	AllLoaded bool `json:"all_loaded"`
@@ -75,6 +127,45 @@ type DownloadsResponse struct {
 	Checkpoints Checkpoints `json:"checkpoints"`
 }
 
+// LibtorrentSettings is the libtorrent section of Tribler's settings. Rates are in KiB/s, 0 means unlimited.
+type LibtorrentSettings struct {
+	Port                   int                      `json:"port"`
+	MaxDownloadRate        int                      `json:"max_download_rate"`
+	MaxUploadRate          int                      `json:"max_upload_rate"`
+	MaxConnectionsDownload int                      `json:"max_connections_download"`
+	Dht                    bool                     `json:"dht"`
+	Utp                    bool                     `json:"utp"`
+	Upnp                   bool                     `json:"upnp"`
+	Natpmp                 bool                     `json:"natpmp"`
+	Lsd                    bool                     `json:"lsd"`
+	DownloadDefaults       DownloadDefaultsSettings `json:"download_defaults"`
+}
+
+// DownloadDefaultsSettings are the settings Tribler applies to new downloads
+type DownloadDefaultsSettings struct {
+	Saveas             string  `json:"saveas"`
+	NumberHops         int     `json:"number_hops"`
+	AnonymityEnabled   bool    `json:"anonymity_enabled"`
+	SafeseedingEnabled bool    `json:"safeseeding_enabled"`
+	SeedingMode        string  `json:"seeding_mode"`
+	SeedingRatio       float64 `json:"seeding_ratio"`
+	SeedingTime        float64 `json:"seeding_time"`
+}
+
+// Settings are the sections of Tribler's settings the shim uses
+type Settings struct {
+	Libtorrent LibtorrentSettings `json:"libtorrent"`
+}
+
+type SettingsResponse struct {
+	Settings Settings `json:"settings"`
+}
+
+type LibtorrentSessionResponse struct {
+	Hop     int                `json:"hop"`
+	Session map[string]float64 `json:"session"`
+}
+
 type AddDownloadResponse struct {
 	Infohash string `json:"infohash"`
 	Started  bool   `json:"started"`
@@ -84,9 +175,11 @@ const (
 	apiKeyHeader           = "X-Api-Key"
 	triblerAPIEndpointEnv  = "TRIBLER_API_ENDPOINT"
 	triblerAPIKeyEnv       = "TRIBLER_API_KEY"
-	triblerDownloadDirEnv  = "TRIBLER_DOWNLOAD_DIR"
 	tlsSkipVerifyEnv       = "TLS_SKIP_VERIFY"
 	defaultDownloadTimeout = 5 * time.Second
+	// healthCheckTimeout is how long Tribler waits for trackers to answer a health check. The request itself
+	// is given a few more seconds on top.
+	healthCheckTimeout = 15 * time.Second
 )
 
 func newHTTPClient() (*http.Client, error) {
@@ -114,23 +207,32 @@ func getTLSConfig() (*tls.Config, error) {
 	return nil, nil
 }
 
-func newDownloadRequest(method, path string, hash string, body map[string]interface{}) (*http.Request, error) {
+// apiURL builds the URL of a Tribler API path and returns it together with the API key
+func apiURL(path string) (*url.URL, string, error) {
 	apiEndpoint := os.Getenv(triblerAPIEndpointEnv)
 	if apiEndpoint == "" {
-		return nil, errors.New("TRIBLER_API_ENDPOINT environment variable is not set")
+		return nil, "", errors.New("TRIBLER_API_ENDPOINT environment variable is not set")
 	}
 
 	apiKey := os.Getenv(triblerAPIKeyEnv)
 	if apiKey == "" {
-		return nil, errors.New("TRIBLER_API_KEY environment variable is not set")
+		return nil, "", errors.New("TRIBLER_API_KEY environment variable is not set")
 	}
 
 	u, err := url.Parse(apiEndpoint)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	u.Path = path
+	return u, apiKey, nil
+}
+
+func newDownloadRequest(method, path string, hash string, body map[string]interface{}) (*http.Request, error) {
+	u, apiKey, err := apiURL(path)
+	if err != nil {
+		return nil, err
+	}
 
 	var buf bytes.Buffer
 	var query url.Values
@@ -143,7 +245,7 @@ func newDownloadRequest(method, path string, hash string, body map[string]interf
 	log.Println("Path=", u.Path)
 	log.Println("Query=", u.RawQuery)
 
-	if method == "PUT" || method == "PATCH" || method == "DELETE" {
+	if method == "PUT" || method == "PATCH" || method == "DELETE" || method == "POST" {
 		if err := json.NewEncoder(&buf).Encode(body); err != nil {
 			return nil, err
 		}
@@ -153,7 +255,7 @@ func newDownloadRequest(method, path string, hash string, body map[string]interf
 		return nil, err
 	}
 	req.Header.Set(apiKeyHeader, apiKey)
-	if method == "PUT" || method == "DELETE" || method == "PATCH" {
+	if method == "PUT" || method == "DELETE" || method == "PATCH" || method == "POST" {
 		req.Header.Set("Content-Type", "application/json")
 	}
 
@@ -199,6 +301,15 @@ func GetDownloads() (DownloadsResponse, error) {
 }
 
 func GetDownload(hash string) (Download, error) {
+	return getDownload(hash, false)
+}
+
+// GetDownloadWithPeers returns a download including its peer list
+func GetDownloadWithPeers(hash string) (Download, error) {
+	return getDownload(hash, true)
+}
+
+func getDownload(hash string, withPeers bool) (Download, error) {
 	client, err := newHTTPClient()
 	if err != nil {
 		return Download{}, err
@@ -209,6 +320,11 @@ func GetDownload(hash string) (Download, error) {
 		log.Println("Error can't make request")
 		return Download{}, err
 	}
+	if withPeers {
+		query := req.URL.Query()
+		query.Set("get_peers", "1")
+		req.URL.RawQuery = query.Encode()
+	}
 
 	log.Printf("GetDownload Request=%v", req.URL)
 	body, err := executeDownloadRequest(client, req)
@@ -233,11 +349,7 @@ func GetDownload(hash string) (Download, error) {
 	return dr.Downloads[0], nil
 }
 
-func AddDownload(uri string) (string, error) {
-	client, err := newHTTPClient()
-	if err != nil {
-		return "", err
-	}
+func anonHops() int {
 	hops := os.Getenv("TRIBLER_ANON_HOPS")
 	if hops == "" {
 		hops = "2" // Set default value if not set
@@ -246,12 +358,20 @@ func AddDownload(uri string) (string, error) {
 	if err != nil {
 		log.Fatal("Error converting TRIBLER_ANON_HOPS to int:", err)
 	}
+	return hopsInt
+}
+
+func AddDownload(uri string, destination string) (string, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return "", err
+	}
 
 	body := map[string]interface{}{
-		"anon_hops":    hopsInt,
+		"anon_hops":    anonHops(),
 		"safe_seeding": true,
 		"uri":          uri,
-		"destination":  os.Getenv(triblerDownloadDirEnv),
+		"destination":  destination,
 	}
 	log.Println("AddDownload.body", body)
 	req, err := newDownloadRequest("PUT", "/downloads", "", body)
@@ -260,12 +380,52 @@ func AddDownload(uri string) (string, error) {
 	}
 
 	response, err := executeDownloadRequest(client, req)
+	if err != nil {
+		return "", err
+	}
 	// unmarsall response to get infohash
 	var adr AddDownloadResponse
 	if err := json.Unmarshal(response, &adr); err != nil {
 		return "", err
 	}
-	return adr.Infohash, err
+	return adr.Infohash, nil
+}
+
+// AddTorrentFile uploads the content of a .torrent file to Tribler. The download parameters are passed
+// in the query string as the request body is the torrent itself.
+func AddTorrentFile(content []byte, destination string) (string, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return "", err
+	}
+
+	u, apiKey, err := apiURL("/downloads")
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("anon_hops", strconv.Itoa(anonHops()))
+	query.Set("safe_seeding", "true")
+	query.Set("destination", destination)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("PUT", u.String(), bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set(apiKeyHeader, apiKey)
+	// Tribler matches on this exact (misspelled) content type to tell torrent uploads from JSON requests
+	req.Header.Set("Content-Type", "applications/x-bittorrent")
+
+	response, err := executeDownloadRequest(client, req)
+	if err != nil {
+		return "", err
+	}
+	var adr AddDownloadResponse
+	if err := json.Unmarshal(response, &adr); err != nil {
+		return "", err
+	}
+	return adr.Infohash, nil
 }
 
 func GetDownloadsFiles(hash string) (TorrentFiles, error) {
@@ -325,17 +485,64 @@ func DeleteDownload(hash string, removeData bool) error {
 	return err
 }
 
+func patchDownload(hash string, body map[string]interface{}) error {
+	client, err := newHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	req, err := newDownloadRequest("PATCH", "/downloads/"+hash, "", body)
+	if err != nil {
+		return err
+	}
+
+	_, err = executeDownloadRequest(client, req)
+	return err
+}
+
 func UpdateDownload(hash string, state string) error {
+	return patchDownload(hash, map[string]interface{}{
+		"state": state,
+	})
+}
+
+// MoveDownload asks Tribler to move the data of a download to destDir
+func MoveDownload(hash string, destDir string) error {
+	return patchDownload(hash, map[string]interface{}{
+		"state":    "move_storage",
+		"dest_dir": destDir,
+	})
+}
+
+// SetSelectedFiles selects the files of a download to download by index, the others are skipped
+func SetSelectedFiles(hash string, indexes []int) error {
+	return patchDownload(hash, map[string]interface{}{
+		"selected_files": indexes,
+	})
+}
+
+// AddTracker adds a tracker to a download
+func AddTracker(hash string, trackerURL string) error {
+	return trackerRequest("PUT", hash, trackerURL)
+}
+
+// RemoveTracker removes a tracker from a download
+func RemoveTracker(hash string, trackerURL string) error {
+	return trackerRequest("DELETE", hash, trackerURL)
+}
+
+// ForceAnnounce makes a download announce to a tracker right away
+func ForceAnnounce(hash string, trackerURL string) error {
 	client, err := newHTTPClient()
 	if err != nil {
 		return err
 	}
 
 	body := map[string]interface{}{
-		"state": state,
+		"url": trackerURL,
 	}
 
-	req, err := newDownloadRequest("PATCH", "/downloads/"+hash, "", body)
+	req, err := newDownloadRequest("PUT", "/downloads/"+hash+"/tracker_force_announce", "", body)
 	if err != nil {
 		return err
 	}
@@ -343,3 +550,152 @@ func UpdateDownload(hash string, state string) error {
 	_, err = executeDownloadRequest(client, req)
 	return err
 }
+
+func trackerRequest(method string, hash string, trackerURL string) error {
+	client, err := newHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"url": trackerURL,
+	}
+
+	req, err := newDownloadRequest(method, "/downloads/"+hash+"/trackers", "", body)
+	if err != nil {
+		return err
+	}
+
+	_, err = executeDownloadRequest(client, req)
+	return err
+}
+
+// tagPredicate is the predicate Tribler's knowledge store uses for tags
+const tagPredicate = 101
+
+// SetDownloadTags replaces the tags the user gave a torrent in Tribler's knowledge store
+func SetDownloadTags(hash string, tags []string) error {
+	client, err := newHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	statements := []map[string]interface{}{}
+	for _, tag := range tags {
+		statements = append(statements, map[string]interface{}{"predicate": tagPredicate, "object": tag})
+	}
+	body := map[string]interface{}{
+		"statements": statements,
+	}
+
+	req, err := newDownloadRequest("PATCH", "/knowledge/"+hash, "", body)
+	if err != nil {
+		return err
+	}
+
+	_, err = executeDownloadRequest(client, req)
+	return err
+}
+
+// GetSettings returns Tribler's settings
+func GetSettings() (Settings, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return Settings{}, err
+	}
+
+	req, err := newDownloadRequest("GET", "/settings", "", nil)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	body, err := executeDownloadRequest(client, req)
+	if err != nil {
+		return Settings{}, err
+	}
+
+	var sr SettingsResponse
+	if err := json.Unmarshal(body, &sr); err != nil {
+		return Settings{}, err
+	}
+	return sr.Settings, nil
+}
+
+// UpdateSettings changes Tribler's settings. Only the sections and keys present in settings are changed.
+func UpdateSettings(settings map[string]interface{}) error {
+	client, err := newHTTPClient()
+	if err != nil {
+		return err
+	}
+
+	req, err := newDownloadRequest("POST", "/settings", "", settings)
+	if err != nil {
+		return err
+	}
+
+	_, err = executeDownloadRequest(client, req)
+	return err
+}
+
+// GetLibtorrentSession returns the libtorrent session statistics of the session with the given number of hops
+func GetLibtorrentSession(hop int) (LibtorrentSessionResponse, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return LibtorrentSessionResponse{}, err
+	}
+
+	u, apiKey, err := apiURL("/libtorrent/session")
+	if err != nil {
+		return LibtorrentSessionResponse{}, err
+	}
+	query := u.Query()
+	query.Set("hop", strconv.Itoa(hop))
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return LibtorrentSessionResponse{}, err
+	}
+	req.Header.Set(apiKeyHeader, apiKey)
+
+	body, err := executeDownloadRequest(client, req)
+	if err != nil {
+		return LibtorrentSessionResponse{}, err
+	}
+
+	var lsr LibtorrentSessionResponse
+	if err := json.Unmarshal(body, &lsr); err != nil {
+		return LibtorrentSessionResponse{}, err
+	}
+	return lsr, nil
+}
+
+// GetTorrentHealth asks Tribler to check the swarm of a torrent with its trackers and the DHT. Tribler waits
+// up to healthCheckTimeout for the answers. Versions of Tribler that report the results asynchronously
+// answer without them, which gives an empty result.
+func GetTorrentHealth(hash string) (map[string]TrackerHealth, error) {
+	client, err := newHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = healthCheckTimeout + defaultDownloadTimeout
+
+	req, err := newDownloadRequest("GET", "/metadata/torrents/"+hash+"/health", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	query := req.URL.Query()
+	query.Set("timeout", strconv.Itoa(int(healthCheckTimeout.Seconds())))
+	req.URL.RawQuery = query.Encode()
+
+	body, err := executeDownloadRequest(client, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var thr TorrentHealthResponse
+	if err := json.Unmarshal(body, &thr); err != nil {
+		return nil, err
+	}
+	return thr.Health, nil
+}
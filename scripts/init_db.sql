@@ -1,9 +1,9 @@
--- add torrent table if not exists, add fields: hash, category_id, created_at, updated_at
+-- add torrent table if not exists, add fields: hash, category_id (NULL when the torrent has no category), created_at, updated_at
 
 CREATE TABLE IF NOT EXISTS torrent (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     hash TEXT NOT NULL UNIQUE,
-    category_id INTEGER NOT NULL,
+    category_id INTEGER,
     FOREIGN KEY (category_id) REFERENCES category(id)
 );
 
@@ -14,3 +14,90 @@ CREATE TABLE IF NOT EXISTS category (
     name TEXT NOT NULL UNIQUE,
     savePath TEXT NOT NULL
 );
+
+-- add session table, add fields: sid, expires_at (unix timestamp)
+
+CREATE TABLE IF NOT EXISTS session (
+    sid TEXT PRIMARY KEY,
+    expires_at INTEGER NOT NULL
+);
+
+-- add tag table, add fields: name
+
+CREATE TABLE IF NOT EXISTS tag (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+-- add torrent_tag table, add fields: hash, tag_id. Rows are keyed by hash so tags stay with torrents that have no category
+
+CREATE TABLE IF NOT EXISTS torrent_tag (
+    hash TEXT NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (hash, tag_id),
+    FOREIGN KEY (tag_id) REFERENCES tag(id)
+);
+
+-- add preference table, add fields: key, value (JSON encoded)
+
+CREATE TABLE IF NOT EXISTS preference (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+-- add torrent_share_limit table, add fields: hash, ratio_limit, seeding_time_limit, inactive_seeding_time_limit (minutes, -2 = global limit, -1 = no limit)
+
+CREATE TABLE IF NOT EXISTS torrent_share_limit (
+    hash TEXT PRIMARY KEY,
+    ratio_limit REAL NOT NULL,
+    seeding_time_limit INTEGER NOT NULL,
+    inactive_seeding_time_limit INTEGER NOT NULL
+);
+
+-- add torrent_stats table, add fields: hash, completed_on, seeding_time, active_time (seconds), last_activity, last_seen_complete (unix timestamps)
+
+CREATE TABLE IF NOT EXISTS torrent_stats (
+    hash TEXT PRIMARY KEY,
+    completed_on INTEGER NOT NULL DEFAULT 0,
+    seeding_time INTEGER NOT NULL DEFAULT 0,
+    last_activity INTEGER NOT NULL DEFAULT 0,
+    active_time INTEGER NOT NULL DEFAULT 0,
+    last_seen_complete INTEGER NOT NULL DEFAULT 0
+);
+
+-- add torrent_queue table, add fields: hash, position, queued (1 when the shim stopped the torrent to respect the queue limits)
+
+CREATE TABLE IF NOT EXISTS torrent_queue (
+    hash TEXT PRIMARY KEY,
+    position INTEGER NOT NULL,
+    queued INTEGER NOT NULL DEFAULT 0
+);
+
+-- add torrent_force_start table, add fields: hash. Force started torrents are exempt from the queue and share limits
+
+CREATE TABLE IF NOT EXISTS torrent_force_start (
+    hash TEXT PRIMARY KEY
+);
+
+-- add torrent_name table, add fields: hash, name (display name set with /torrents/rename)
+
+CREATE TABLE IF NOT EXISTS torrent_name (
+    hash TEXT PRIMARY KEY,
+    name TEXT NOT NULL
+);
+
+-- add torrent_file_name table, add fields: hash, file_index, name (path of a renamed file relative to the save path)
+
+CREATE TABLE IF NOT EXISTS torrent_file_name (
+    hash TEXT NOT NULL,
+    file_index INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    PRIMARY KEY (hash, file_index)
+);
+
+-- add torrent_save_path table, add fields: hash, save_path (where a torrent downloading into the temp path is moved once complete)
+
+CREATE TABLE IF NOT EXISTS torrent_save_path (
+    hash TEXT PRIMARY KEY,
+    save_path TEXT NOT NULL
+);